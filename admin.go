@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/log"
+	"golang.org/x/time/rate"
+)
+
+var adminToken = newSecret("adminToken", "Bearer token required by the /-/ admin endpoints. Admin endpoints are disabled when empty, unless authMode is kubernetes.")
+
+var adminRateLimit = flag.Float64("adminRateLimit", defaultAdminRateLimit, "Requests per second allowed to the admin endpoints from each client IP.")
+var adminRateBurst = flag.Int("adminRateBurst", defaultAdminRateBurst, "Burst of requests allowed to the admin endpoints from each client IP.")
+var adminAllowedCIDRs = flag.String("adminAllowedCIDRs", "", "Comma separated CIDRs of clients allowed to call the admin endpoints. Any client is allowed when empty.")
+
+type adminClient struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+var (
+	adminLimiterMu sync.Mutex
+	adminLimiters  = map[string]*adminClient{}
+)
+
+// allowAdminRequest takes a token from the bucket of the client IP, so a
+// client exhausting its bucket does not lock the others out. Buckets idle
+// long enough to be full again are dropped.
+func allowAdminRequest(r *http.Request, now time.Time) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	adminLimiterMu.Lock()
+	defer adminLimiterMu.Unlock()
+	if *adminRateLimit > 0 {
+		refill := time.Duration(float64(*adminRateBurst) / *adminRateLimit * float64(time.Second))
+		for k, c := range adminLimiters {
+			if now.Sub(c.seen) > refill {
+				delete(adminLimiters, k)
+			}
+		}
+	}
+	c, ok := adminLimiters[host]
+	if !ok {
+		c = &adminClient{limiter: rate.NewLimiter(rate.Limit(*adminRateLimit), *adminRateBurst)}
+		adminLimiters[host] = c
+	}
+	c.seen = now
+	return c.limiter.AllowN(now, 1)
+}
+
+var loggingPaused int32
+
+func isLoggingPaused() bool {
+	return atomic.LoadInt32(&loggingPaused) == 1
+}
+
+func setLoggingPaused(paused bool) {
+	if paused {
+		atomic.StoreInt32(&loggingPaused, 1)
+		conditionLoggingPaused.Set(1)
+	} else {
+		atomic.StoreInt32(&loggingPaused, 0)
+		conditionLoggingPaused.Set(0)
+	}
+}
+
+func parseCIDRs(s string) ([]*net.IPNet, error) {
+	ret := []*net.IPNet{}
+	for _, c := range splitList(s) {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, n)
+	}
+	return ret, nil
+}
+
+func adminClientAllowed(r *http.Request) bool {
+	cidrs, _ := parseCIDRs(*adminAllowedCIDRs)
+	if len(cidrs) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, n := range cidrs {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin leaves authorization to RBAC when authMode is kubernetes.
+func requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !adminClientAllowed(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !allowAdminRequest(r, time.Now()) {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		if *authMode == "kubernetes" {
+			h(w, r)
+			return
+		}
+		token := adminToken.Get()
+		if token == "" {
+			http.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+		t := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		setAuditIdentity(r, "adminToken")
+		h(w, r)
+	}
+}
+
+func collectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := collectMetrics(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("collected\n"))
+}
+
+func loggingPauseHandler(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		setLoggingPaused(paused)
+		if paused {
+			log.Info("condition logging paused")
+			w.Write([]byte("condition logging paused\n"))
+		} else {
+			log.Info("condition logging resumed")
+			w.Write([]byte("condition logging resumed\n"))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAllowAdminRequestPerClient(t *testing.T) {
+	defer func(l float64, b int) { *adminRateLimit, *adminRateBurst = l, b }(*adminRateLimit, *adminRateBurst)
+	defer func() { adminLimiters = map[string]*adminClient{} }()
+	*adminRateLimit, *adminRateBurst = 0.1, 1
+	adminLimiters = map[string]*adminClient{}
+	request := func(addr string) bool {
+		r := httptest.NewRequest("POST", "/-/collect", nil)
+		r.RemoteAddr = addr
+		return allowAdminRequest(r, testTime)
+	}
+	if !request("10.0.0.1:40000") {
+		t.Fatal("first request denied")
+	}
+	if request("10.0.0.1:40001") {
+		t.Fatal("second request of the client allowed")
+	}
+	if !request("10.0.0.2:40000") {
+		t.Fatal("request of another client denied")
+	}
+	r := httptest.NewRequest("POST", "/-/collect", nil)
+	r.RemoteAddr = "10.0.0.1:40002"
+	if !allowAdminRequest(r, testTime.Add(11*time.Second)) {
+		t.Fatal("request after the bucket refilled denied")
+	}
+	if len(adminLimiters) != 1 {
+		t.Fatalf("got %d buckets, want the idle one dropped", len(adminLimiters))
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// alertmanagerConfig sends findings as alerts to the Alertmanager API, so
+// they go through its routing, grouping and silences.
+type alertmanagerConfig struct {
+	URL         string            `yaml:"url"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+	Headers     map[string]string `yaml:"headers"`
+	Timeout     time.Duration     `yaml:"timeout"`
+	TLS         tlsFiles          `yaml:"tls"`
+}
+
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+type alertmanagerReceiver struct {
+	url         string
+	client      *http.Client
+	labels      map[string]*template.Template
+	annotations map[string]*template.Template
+	headers     map[string]*template.Template
+	template    *messageTemplate
+}
+
+func parseTemplateMap(kind string, m map[string]string) (map[string]*template.Template, error) {
+	ret := map[string]*template.Template{}
+	for k, v := range m {
+		t, err := parseNotifyTemplate(kind+" "+k, v)
+		if err != nil {
+			return nil, err
+		}
+		ret[k] = t
+	}
+	return ret, nil
+}
+
+func executeTemplateMap(ts map[string]*template.Template, data interface{}, into map[string]string) error {
+	for k, t := range ts {
+		v, err := executeTemplate(t, data)
+		if err != nil {
+			return err
+		}
+		into[k] = v
+	}
+	return nil
+}
+
+func newAlertmanagerReceiver(c *alertmanagerConfig, t *messageTemplate) (*alertmanagerReceiver, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("alertmanager has no url")
+	}
+	r := &alertmanagerReceiver{
+		url:      strings.TrimSuffix(c.URL, "/") + "/api/v2/alerts",
+		template: t,
+	}
+	var err error
+	if r.labels, err = parseTemplateMap("label", c.Labels); err != nil {
+		return nil, err
+	}
+	if r.annotations, err = parseTemplateMap("annotation", c.Annotations); err != nil {
+		return nil, err
+	}
+	if r.headers, err = parseTemplateMap("header", c.Headers); err != nil {
+		return nil, err
+	}
+	if r.client, err = c.TLS.HTTPClient("alertmanager", c.Timeout); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *alertmanagerReceiver) alert(f finding, now time.Time) (alertmanagerAlert, error) {
+	a := alertmanagerAlert{
+		Labels: map[string]string{
+			"alertname": f.Rule,
+			"severity":  f.Severity,
+		},
+		Annotations: map[string]string{"summary": f.Summary},
+		StartsAt:    f.StartsAt,
+		// resolved by Alertmanager if the exporter stops sending it
+		EndsAt: now.Add(3 * *notifyInterval),
+	}
+	if f.Status == findingResolved {
+		a.EndsAt = now
+	}
+	// message templates become the summary and description annotations
+	if r.template.title != nil || r.template.body != nil {
+		title, body, _, err := r.template.message(f)
+		if err != nil {
+			return a, err
+		}
+		if r.template.title != nil {
+			a.Annotations["summary"] = title
+		}
+		if r.template.body != nil {
+			a.Annotations["description"] = body
+		}
+	}
+	for k, v := range f.Labels {
+		if v != "" {
+			a.Labels[k] = v
+		}
+	}
+	data := newNotifyData([]finding{f})
+	if err := executeTemplateMap(r.labels, data, a.Labels); err != nil {
+		return a, err
+	}
+	if err := executeTemplateMap(r.annotations, data, a.Annotations); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+func (r *alertmanagerReceiver) Notify(fs []finding) error {
+	now := time.Now()
+	alerts := []alertmanagerAlert{}
+	for _, f := range fs {
+		a, err := r.alert(f, now)
+		if err != nil {
+			return err
+		}
+		alerts = append(alerts, a)
+	}
+	header := http.Header{}
+	h := map[string]string{}
+	if err := executeTemplateMap(r.headers, newNotifyData(fs), h); err != nil {
+		return err
+	}
+	for k, v := range h {
+		header.Set(k, v)
+	}
+	return postJSON(r.client, r.url, header, alerts)
+}
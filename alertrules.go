@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	alertAtMaxFor              = model.Duration(defaultAlertAtMaxFor)
+	alertMetricsUnavailableFor = model.Duration(defaultAlertMetricsUnavailableFor)
+	alertUnableToScaleFor      = model.Duration(defaultAlertUnableToScaleFor)
+	alertExporterDownFor       = model.Duration(defaultAlertExporterDownFor)
+	alertExporterJob           = flag.String("alertExporterJob", defaultAlertExporterJob, "Prometheus job name of this exporter, used by the generated exporter down alert.")
+	alertSeverity              = flag.String("alertSeverity", defaultAlertSeverity, "severity label of the generated alerting rules.")
+	alertPrometheusRuleName    = flag.String("alertPrometheusRuleName", defaultAlertPrometheusRuleName, "metadata.name of the generated PrometheusRule.")
+	alertPrometheusRuleLabels  = flag.String("alertPrometheusRuleLabels", "", "Comma separated key=value labels added to the generated PrometheusRule metadata.")
+)
+
+func init() {
+	flag.Var(&alertAtMaxFor, "alertAtMaxFor", "Duration an HPA must stay at max replicas before the generated alert fires.")
+	flag.Var(&alertMetricsUnavailableFor, "alertMetricsUnavailableFor", "Duration ScalingActive must be false before the generated alert fires.")
+	flag.Var(&alertUnableToScaleFor, "alertUnableToScaleFor", "Duration AbleToScale must be false before the generated alert fires.")
+	flag.Var(&alertExporterDownFor, "alertExporterDownFor", "Duration the exporter must be down before the generated alert fires.")
+}
+
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type prometheusRule struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name   string            `yaml:"name"`
+		Labels map[string]string `yaml:"labels,omitempty"`
+	} `yaml:"metadata"`
+	Spec ruleGroups `yaml:"spec"`
+}
+
+func alertRuleGroup() ruleGroup {
+	labels := map[string]string{"severity": *alertSeverity}
+	return ruleGroup{
+		Name: "hpa-exporter",
+		Rules: []alertRule{
+			{
+				Alert:  "HPAAtMaxReplicas",
+				Expr:   "hpa_current_pods_num >= hpa_max_pods_num",
+				For:    alertAtMaxFor.String(),
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary":     "HPA {{ $labels.hpa_namespace }}/{{ $labels.hpa_name }} is running at max replicas.",
+					"description": fmt.Sprintf("HPA {{ $labels.hpa_namespace }}/{{ $labels.hpa_name }} has been at max replicas for more than %s.", alertAtMaxFor),
+				},
+			},
+			{
+				Alert:  "HPAMetricsUnavailable",
+				Expr:   `hpa_scaling_active{cond_status="False"} == 1`,
+				For:    alertMetricsUnavailableFor.String(),
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary":     "HPA {{ $labels.hpa_namespace }}/{{ $labels.hpa_name }} cannot compute metrics.",
+					"description": "ScalingActive is false: {{ $labels.cond_reason }} {{ $labels.cond_message }}",
+				},
+			},
+			{
+				Alert:  "HPAUnableToScale",
+				Expr:   `hpa_able_to_scale{cond_status="False"} == 1`,
+				For:    alertUnableToScaleFor.String(),
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary":     "HPA {{ $labels.hpa_namespace }}/{{ $labels.hpa_name }} is unable to scale.",
+					"description": "AbleToScale is false: {{ $labels.cond_reason }} {{ $labels.cond_message }}",
+				},
+			},
+			{
+				Alert:  "HPAExporterDown",
+				Expr:   fmt.Sprintf(`up{job=%q} == 0 or absent(up{job=%q})`, *alertExporterJob, *alertExporterJob),
+				For:    alertExporterDownFor.String(),
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary":     "HPA exporter is down.",
+					"description": fmt.Sprintf("No HPA metrics have been scraped from job %s for more than %s.", *alertExporterJob, alertExporterDownFor),
+				},
+			},
+		},
+	}
+}
+
+func alertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	var out interface{}
+	switch r.URL.Query().Get("format") {
+	case "", "prometheusrule":
+		pr := prometheusRule{
+			APIVersion: "monitoring.coreos.com/v1",
+			Kind:       "PrometheusRule",
+			Spec:       ruleGroups{Groups: []ruleGroup{alertRuleGroup()}},
+		}
+		pr.Metadata.Name = *alertPrometheusRuleName
+		pr.Metadata.Labels = parseKeyValues(*alertPrometheusRuleLabels)
+		out = pr
+	case "rules":
+		out = ruleGroups{Groups: []ruleGroup{alertRuleGroup()}}
+	default:
+		http.Error(w, "format must be either `prometheusrule` or `rules`", http.StatusBadRequest)
+		return
+	}
+	b, err := yaml.Marshal(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(b)
+}
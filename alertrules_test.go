@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestAlertRulesHandler(t *testing.T) {
+	defer setFlagValues(flagValues())
+	for name, v := range map[string]string{
+		"alertAtMaxFor":             "30m",
+		"alertExporterJob":          "hpa",
+		"alertSeverity":             "critical",
+		"alertPrometheusRuleName":   "hpa-rules",
+		"alertPrometheusRuleLabels": "release=prom,team=platform",
+	} {
+		if err := flag.Set(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		format string
+		status int
+		kind   string
+	}{
+		{"", http.StatusOK, "PrometheusRule"},
+		{"prometheusrule", http.StatusOK, "PrometheusRule"},
+		{"rules", http.StatusOK, ""},
+		{"json", http.StatusBadRequest, ""},
+	} {
+		w := httptest.NewRecorder()
+		alertRulesHandler(w, httptest.NewRequest("GET", "/api/v1/alert-rules?format="+c.format, nil))
+		if w.Code != c.status {
+			t.Errorf("format `%s`: status %d, want %d", c.format, w.Code, c.status)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		pr := prometheusRule{}
+		if err := yaml.UnmarshalStrict(w.Body.Bytes(), &pr); c.kind != "" && err != nil {
+			t.Fatalf("format `%s`: %v", c.format, err)
+		}
+		groups := pr.Spec
+		if c.kind == "" {
+			if err := yaml.UnmarshalStrict(w.Body.Bytes(), &groups); err != nil {
+				t.Fatalf("format `%s`: %v", c.format, err)
+			}
+		} else if pr.Kind != c.kind || pr.Metadata.Name != "hpa-rules" || pr.Metadata.Labels["team"] != "platform" {
+			t.Errorf("format `%s`: kind %s, metadata %+v", c.format, pr.Kind, pr.Metadata)
+		}
+		if len(groups.Groups) != 1 {
+			t.Fatalf("format `%s`: %d groups, want 1", c.format, len(groups.Groups))
+		}
+		rules := map[string]alertRule{}
+		for _, r := range groups.Groups[0].Rules {
+			rules[r.Alert] = r
+			if r.Labels["severity"] != "critical" {
+				t.Errorf("%s: severity %s", r.Alert, r.Labels["severity"])
+			}
+		}
+		if r := rules["HPAAtMaxReplicas"]; r.For != "30m" || !strings.Contains(r.Annotations["description"], "30m") {
+			t.Errorf("HPAAtMaxReplicas for %s: %s", r.For, r.Annotations["description"])
+		}
+		if r := rules["HPAExporterDown"]; r.Expr != `up{job="hpa"} == 0 or absent(up{job="hpa"})` || r.For != "5m" {
+			t.Errorf("HPAExporterDown: %s for %s", r.Expr, r.For)
+		}
+		for _, name := range []string{"HPAMetricsUnavailable", "HPAUnableToScale"} {
+			if _, ok := rules[name]; !ok {
+				t.Errorf("format `%s`: no rule %s", c.format, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// amqpMethodFrame returns a method frame on channel 1.
+func amqpMethodFrame(m [2]uint16, args []byte) []byte {
+	p := &amqpArgs{}
+	p.short(m[0])
+	p.short(m[1])
+	p.Write(args)
+	return (&amqpConn{}).frame(amqpFrameMethod, 1, p.Bytes())
+}
+
+func TestAMQPArgs(t *testing.T) {
+	a := &amqpArgs{}
+	a.shortstr("abc")
+	a.longstr("de")
+	a.table(map[string]string{"k": "v"})
+	want := "\x03abc" + "\x00\x00\x00\x02de" + "\x00\x00\x00\x08" + "\x01kS\x00\x00\x00\x01v"
+	if got := a.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestAMQPExpect(t *testing.T) {
+	closeArgs := &amqpArgs{}
+	closeArgs.short(404)
+	closeArgs.shortstr("NOT_FOUND - no exchange 'hpa'")
+	closeArgs.short(60)
+	closeArgs.short(40)
+	heartbeat := (&amqpConn{}).frame(amqpFrameHeartbeat, 0, nil)
+	tests := []struct {
+		name    string
+		in      []byte
+		wantErr string
+	}{
+		{"ack", amqpMethodFrame(amqpBasicAck, make([]byte, 9)), ""},
+		{"heartbeat skipped", append(heartbeat, amqpMethodFrame(amqpBasicAck, make([]byte, 9))...), ""},
+		{"nack", amqpMethodFrame(amqpBasicNack, make([]byte, 9)), "not accepted"},
+		{"channel close", amqpMethodFrame(amqpChannelClose, closeArgs.Bytes()), "closed by broker: NOT_FOUND - no exchange 'hpa'"},
+		{"unexpected", amqpMethodFrame(amqpChannelOpenOk, nil), "unexpected method 20.11"},
+		{"frame end", append(amqpMethodFrame(amqpBasicAck, nil)[:11], 0), "invalid frame end"},
+		{"short method", (&amqpConn{}).frame(amqpFrameMethod, 1, []byte{0}), "short method frame"},
+		{"frame max", []byte{amqpFrameMethod, 0, 1, 0xff, 0xff, 0xff, 0xff}, "exceeds frame max"},
+		{"truncated", amqpMethodFrame(amqpBasicAck, nil)[:5], "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &amqpConn{r: bufio.NewReader(bytes.NewReader(tt.in)), frameMax: 131072}
+			_, err := c.expect(amqpBasicAck)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// readAMQPFrame reads a frame and returns its type.
+func readAMQPFrame(r io.Reader) (uint8, error) {
+	h := make([]byte, 7)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return 0, err
+	}
+	_, err := io.ReadFull(r, make([]byte, binary.BigEndian.Uint32(h[3:7])+1))
+	return h[0], err
+}
+
+// amqpBroker reads a published message, method, header and body frames,
+// and answers with reply.
+func amqpBroker(conn net.Conn, reply [2]uint16, published chan<- bool) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for i := 0; i < 3; i++ {
+		if _, err := readAMQPFrame(r); err != nil {
+			return
+		}
+	}
+	published <- true
+	conn.Write(amqpMethodFrame(reply, make([]byte, 9)))
+	io.Copy(ioutil.Discard, r)
+}
+
+func TestAMQPSinkKeepsChanges(t *testing.T) {
+	published := make(chan bool, 2)
+	dial := func(reply [2]uint16) *amqpConn {
+		client, server := net.Pipe()
+		go amqpBroker(server, reply, published)
+		return &amqpConn{conn: client, r: bufio.NewReader(client), frameMax: 131072}
+	}
+	s := &amqpSink{key: template.Must(template.New("key").Parse(defaultAMQPRoutingKey))}
+	if err := s.Write(trackerHpa(2), testTime); err != nil {
+		t.Fatal(err)
+	}
+	s.conn = dial(amqpBasicNack)
+	if err := s.Write(trackerHpa(3), testTime); err == nil {
+		t.Fatal("want the nack error")
+	}
+	if len(s.tracker.unsent) != 1 {
+		t.Fatalf("got %d unsent changes, want the nacked one", len(s.tracker.unsent))
+	}
+	s.conn = dial(amqpBasicAck)
+	if err := s.Write(trackerHpa(3), testTime); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.tracker.unsent) != 0 {
+		t.Fatalf("got %d unsent changes after the ack", len(s.tracker.unsent))
+	}
+	if len(published) != 2 {
+		t.Fatalf("published %d messages, want the nacked one again", len(published))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var corsAllowedOrigins = flag.String("corsAllowedOrigins", "", "Comma separated origins allowed to call the /api/ endpoints from a browser, or `*` for any.")
+var corsAllowedHeaders = flag.String("corsAllowedHeaders", defaultCORSAllowedHeaders, "Comma separated request headers allowed in CORS requests to the /api/ endpoints.")
+
+func corsOriginAllowed(origin string) bool {
+	for _, o := range strings.Split(*corsAllowedOrigins, ",") {
+		o = strings.TrimSpace(o)
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func withCORS(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && *corsAllowedOrigins != "" && corsOriginAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", *corsAllowedHeaders)
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+var hpaCSVHeader = []string{
+	"namespace",
+	"name",
+	"ref_kind",
+	"ref_name",
+	"min_replicas",
+	"max_replicas",
+	"current_replicas",
+	"desired_replicas",
+	"target_metrics",
+	"current_metrics",
+	"conditions",
+}
+
+func formatMetrics(ms []commonMetrics) string {
+	s := make([]string, 0, len(ms))
+	for _, m := range ms {
+		s = append(s, fmt.Sprintf("%s/%s/%s=%s", m.Kind, m.Name, m.MetricName, strconv.FormatFloat(m.Value, 'f', -1, 64)))
+	}
+	return strings.Join(s, "; ")
+}
+
+func formatConditions(conds []as_v2.HorizontalPodAutoscalerCondition) string {
+	s := make([]string, 0, len(conds))
+	for _, c := range conds {
+		s = append(s, fmt.Sprintf("%s=%s(%s)", c.Type, c.Status, c.Reason))
+	}
+	return strings.Join(s, "; ")
+}
+
+func hpaCSVRecord(a as_v2.HorizontalPodAutoscaler) []string {
+	var min string
+	if a.Spec.MinReplicas != nil {
+		min = strconv.Itoa(int(*a.Spec.MinReplicas))
+	}
+	return []string{
+		a.ObjectMeta.Namespace,
+		a.ObjectMeta.Name,
+		a.Spec.ScaleTargetRef.Kind,
+		a.Spec.ScaleTargetRef.Name,
+		min,
+		strconv.Itoa(int(a.Spec.MaxReplicas)),
+		strconv.Itoa(int(a.Status.CurrentReplicas)),
+		strconv.Itoa(int(a.Status.DesiredReplicas)),
+		formatMetrics(specMetrics(a)),
+		formatMetrics(statusMetrics(a)),
+		formatConditions(a.Status.Conditions),
+	}
+}
+
+func writeHpasCSV(w io.Writer, hpa []as_v2.HorizontalPodAutoscaler) error {
+	cw := csv.NewWriter(w)
+	cw.Write(hpaCSVHeader)
+	for _, a := range hpa {
+		cw.Write(hpaCSVRecord(a))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func hpasCSVHandler(w http.ResponseWriter, r *http.Request) {
+	hpa, err := apiHpaList(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="hpas.csv"`)
+	writeHpasCSV(w, hpa)
+}
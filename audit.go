@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/prometheus/common/log"
+)
+
+var auditLog = flag.Bool("auditLog", defaultAuditLog, "Log identity, endpoint and result of every HTTP request except health checks and metrics scrapes to loggingTo.")
+var cwAuditLogStream = flag.String("cwAuditLogStream", defaultCWAuditLogStream, "Name of CWLog stream for the audit log.")
+
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	Identity string    `json:"identity"`
+	Remote   string    `json:"remote"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Query    string    `json:"query,omitempty"`
+	Status   int       `json:"status"`
+}
+
+type auditContextKey struct{}
+
+// setAuditIdentity records who the request was authenticated as.
+func setAuditIdentity(r *http.Request, identity string) {
+	if e, ok := r.Context().Value(auditContextKey{}).(*auditEntry); ok {
+		e.Identity = identity
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func withAudit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/-/healthy" || r.URL.Path == *metricsPath || strings.HasPrefix(r.URL.Path, strings.TrimSuffix(*metricsPath, "/")+"/") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		e := &auditEntry{
+			Time:     time.Now(),
+			Identity: "anonymous",
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Query:    r.URL.RawQuery,
+		}
+		e.Remote, _, _ = net.SplitHostPort(r.RemoteAddr)
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			e.Identity = "cn=" + r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, e)))
+		e.Status = rec.status
+		writeAudit(e)
+	})
+}
+
+// maxAuditPending bounds the audit events kept while CloudWatch Logs fails,
+// which is also the most PutLogEvents takes at once.
+const maxAuditPending = 10000
+
+var (
+	auditMu      sync.Mutex
+	auditPending []*cloudwatchlogs.InputLogEvent
+)
+
+// trimAuditPending drops the oldest events beyond maxAuditPending. It must
+// be called holding auditMu.
+func trimAuditPending() {
+	if n := len(auditPending) - maxAuditPending; n > 0 {
+		log.Errorf("dropped %d audit log events", n)
+		auditEventsDropped.Add(float64(n))
+		auditPending = auditPending[n:]
+	}
+}
+
+func writeAudit(e *auditEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if *loggingTo == "file" {
+		err := logFileWriter.writeLines(string(b))
+		if err != nil {
+			log.Errorln(err)
+		}
+		recordLogDelivery("audit", 1, 0, err)
+		return
+	}
+	if *loggingTo != "cwlogs" {
+		log.Infoln("audit:", string(b))
+		recordLogDelivery("audit", 1, 0, nil)
+		return
+	}
+	auditMu.Lock()
+	auditPending = append(auditPending, &cloudwatchlogs.InputLogEvent{
+		Message:   aws.String(string(b)),
+		Timestamp: aws.Int64(e.Time.UnixNano() / int64(time.Millisecond)),
+	})
+	trimAuditPending()
+	logBacklog.WithLabelValues("audit", *loggingTo).Set(float64(len(auditPending)))
+	auditMu.Unlock()
+}
+
+// flushAuditLog sends the audit events to CloudWatch Logs in batches
+// rather than one call per request. A batch that fails is sent again with
+// the next one.
+func flushAuditLog() {
+	for {
+		time.Sleep(5 * time.Second)
+		flushAuditEvents()
+	}
+}
+
+func flushAuditEvents() {
+	auditMu.Lock()
+	events := auditPending
+	auditPending = nil
+	auditMu.Unlock()
+	if len(events) == 0 {
+		return
+	}
+	err := putCWLogEvents(cwAuditLogStream, events)
+	auditMu.Lock()
+	if err != nil {
+		log.Errorln("audit log:", err)
+		// retried at the next flush, before the events written meanwhile
+		auditPending = append(events, auditPending...)
+		trimAuditPending()
+	}
+	recordLogDelivery("audit", len(events), len(auditPending), err)
+	auditMu.Unlock()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterValue(t *testing.T, c interface{ Write(*dto.Metric) error }) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestAuditKeptOnFailure(t *testing.T) {
+	defer setFlagValues(flagValues())
+	defer setCWClient(cwClient())
+	fail := true
+	puts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if r.Header.Get("X-Amz-Target") == "Logs_20140328.DescribeLogStreams" {
+			fmt.Fprint(w, `{"logStreams":[{"logStreamName":"audit","uploadSequenceToken":"1"}]}`)
+			return
+		}
+		puts++
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"__type":"ServiceUnavailableException","message":"down"}`)
+			return
+		}
+		fmt.Fprint(w, `{"nextSequenceToken":"2"}`)
+	}))
+	defer srv.Close()
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(srv.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+	setCWClient(cloudwatchlogs.New(sess))
+	*loggingTo = "cwlogs"
+	auditMu.Lock()
+	auditPending = nil
+	auditMu.Unlock()
+
+	for i := 0; i < 3; i++ {
+		writeAudit(&auditEntry{Time: time.Unix(int64(i), 0), Path: "/-/collect"})
+	}
+	flushAuditEvents()
+	if puts != 1 || len(auditPending) != 3 {
+		t.Fatalf("after a failed put: %d puts, %d pending, want 1 and 3", puts, len(auditPending))
+	}
+
+	dropped := counterValue(t, auditEventsDropped)
+	for i := 0; i < maxAuditPending; i++ {
+		writeAudit(&auditEntry{Time: time.Unix(int64(3+i), 0), Path: "/-/collect"})
+	}
+	if len(auditPending) != maxAuditPending {
+		t.Errorf("%d pending, want at most %d", len(auditPending), maxAuditPending)
+	}
+	if got := counterValue(t, auditEventsDropped) - dropped; got != 3 {
+		t.Errorf("%v events dropped, want the 3 oldest", got)
+	}
+	if ts := *auditPending[0].Timestamp; ts != 3000 {
+		t.Errorf("oldest pending event at %d, want 3000", ts)
+	}
+
+	fail = false
+	flushAuditEvents()
+	if len(auditPending) != 0 {
+		t.Errorf("%d pending after a successful put", len(auditPending))
+	}
+}
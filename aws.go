@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/prometheus/common/log"
+)
+
+var awsRoleARN = flag.String("awsRoleARN", "", "ARN of the IAM role to assume with awsWebIdentityTokenFile (e.g. IRSA). Credentials are detected from the environment when empty.")
+var awsWebIdentityTokenFile = flag.String("awsWebIdentityTokenFile", "", "Path to the web identity token used to assume awsRoleARN.")
+var awsRoleSessionName = flag.String("awsRoleSessionName", defaultAWSRoleSessionName, "Session name used when assuming awsRoleARN.")
+var awsEndpointURL = flag.String("aws-endpoint-url", "", "URL of the AWS API endpoint, e.g. a localstack instance. The regional endpoint is used when empty.")
+var awsUseFIPSEndpoint = flag.Bool("aws-use-fips-endpoint", false, "Use the FIPS endpoints of the AWS APIs.")
+
+// webIdentityProvider assumes a role with a web identity token file, which
+// the token file is re-read for on every refresh since it is rotated.
+type webIdentityProvider struct {
+	credentials.Expiry
+	client      *sts.STS
+	roleARN     string
+	tokenFile   string
+	sessionName string
+}
+
+func (p *webIdentityProvider) Retrieve() (credentials.Value, error) {
+	token, err := ioutil.ReadFile(p.tokenFile)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	out, err := p.client.AssumeRoleWithWebIdentity(&sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.roleARN),
+		RoleSessionName:  aws.String(p.sessionName),
+		WebIdentityToken: aws.String(strings.TrimSpace(string(token))),
+	})
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	p.SetExpiration(*out.Credentials.Expiration, time.Minute)
+	return credentials.Value{
+		AccessKeyID:     *out.Credentials.AccessKeyId,
+		SecretAccessKey: *out.Credentials.SecretAccessKey,
+		SessionToken:    *out.Credentials.SessionToken,
+		ProviderName:    "WebIdentityProvider",
+	}, nil
+}
+
+func validateAWSFlags() error {
+	if (*awsRoleARN == "") != (*awsWebIdentityTokenFile == "") {
+		return fmt.Errorf("flags `awsRoleARN` and `awsWebIdentityTokenFile` must be set together")
+	}
+	return nil
+}
+
+func newAWSSession() (*session.Session, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if *awsRoleARN == "" {
+		return sess, nil
+	}
+	// AssumeRoleWithWebIdentity is not signed.
+	anon := sess.Copy(&aws.Config{Credentials: credentials.AnonymousCredentials})
+	return sess.Copy(&aws.Config{Credentials: credentials.NewCredentials(&webIdentityProvider{
+		client:      sts.New(anon, awsServiceConfig(anon, sts.EndpointsID)),
+		roleARN:     *awsRoleARN,
+		tokenFile:   *awsWebIdentityTokenFile,
+		sessionName: *awsRoleSessionName,
+	})}), nil
+}
+
+// awsServiceConfig points the client of the service at awsEndpointURL, or
+// at the FIPS endpoint of the region, which is the regional host name with
+// -fips appended to the service name.
+func awsServiceConfig(sess *session.Session, service string) *aws.Config {
+	if *awsEndpointURL != "" {
+		return &aws.Config{Endpoint: awsEndpointURL}
+	}
+	if !*awsUseFIPSEndpoint {
+		return &aws.Config{}
+	}
+	region := aws.StringValue(sess.Config.Region)
+	e, err := endpoints.DefaultResolver().EndpointFor(service, region)
+	if err != nil {
+		log.Warnf("resolve %s endpoint: %v", service, err)
+		return &aws.Config{}
+	}
+	fips := service + "-fips."
+	if !strings.Contains(e.URL, "."+region+".") {
+		// global endpoints such as sts.amazonaws.com have regional FIPS ones
+		fips += region + "."
+	}
+	return &aws.Config{Endpoint: aws.String(strings.Replace(e.URL, "://"+service+".", "://"+fips, 1))}
+}
+
+// checkAWSIdentity logs the identity the exporter runs as and checks it is
+// allowed to put log events. IAM is evaluated before the log stream is
+// looked up, so writing to a stream that does not exist tells an access
+// denial apart without writing anything.
+func checkAWSIdentity() error {
+	sess, err := newAWSSession()
+	if err != nil {
+		return err
+	}
+	id, err := sts.New(sess, awsServiceConfig(sess, sts.EndpointsID)).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return err
+	}
+	log.Infoln("AWS identity:", *id.Arn)
+	_, err = cwClient().PutLogEvents(&cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  cwLogGroup,
+		LogStreamName: aws.String(*cwLogStream + "-permission-check"),
+		LogEvents: []*cloudwatchlogs.InputLogEvent{{
+			Message:   aws.String("permission check"),
+			Timestamp: aws.Int64(time.Now().UnixNano() / int64(time.Millisecond)),
+		}},
+	})
+	if ae, ok := err.(awserr.Error); ok && ae.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException {
+		return nil
+	}
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s cannot put log events to %s: %v", *id.Arn, *cwLogGroup, err)
+}
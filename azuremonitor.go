@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var azureMonitorResourceID = flag.String("azureMonitorResourceID", "", "Azure resource ID, e.g. of the AKS cluster, to publish the HPA metrics to as Azure Monitor custom metrics. Nothing is published when empty.")
+var azureMonitorRegion = flag.String("azureMonitorRegion", "", "Azure region of azureMonitorResourceID, e.g. westeurope.")
+var azureMonitorNamespace = flag.String("azureMonitorNamespace", defaultAzureMonitorNamespace, "Metric namespace of the published custom metrics.")
+var azureClientID = flag.String("azureClientID", "", "Client ID of the user-assigned managed identity to authenticate with. $AZURE_CLIENT_ID, then the system-assigned identity are used when empty.")
+
+const (
+	azureMonitorResource = "https://monitoring.azure.com/"
+	azureIMDSTokenURL    = "http://169.254.169.254/metadata/identity/oauth2/token"
+	// custom metrics have at most 10 dimensions
+	azureMonitorMaxDims = 10
+)
+
+// azureTokenSource gets Azure AD access tokens of a managed identity, either
+// by AKS workload identity federation or from the instance metadata service,
+// and caches them until shortly before they expire.
+type azureTokenSource struct {
+	resource string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+var azureTokenClient = &http.Client{Timeout: 30 * time.Second}
+
+func (s *azureTokenSource) clientID() string {
+	if *azureClientID != "" {
+		return *azureClientID
+	}
+	return os.Getenv("AZURE_CLIENT_ID")
+}
+
+func (s *azureTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expiry) {
+		return s.token, nil
+	}
+	var res *http.Response
+	var err error
+	if path := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); path != "" {
+		assertion, e := ioutil.ReadFile(path)
+		if e != nil {
+			return "", e
+		}
+		authority := os.Getenv("AZURE_AUTHORITY_HOST")
+		if authority == "" {
+			authority = "https://login.microsoftonline.com/"
+		}
+		res, err = azureTokenClient.PostForm(strings.TrimSuffix(authority, "/")+"/"+os.Getenv("AZURE_TENANT_ID")+"/oauth2/v2.0/token", url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_id":             {s.clientID()},
+			"scope":                 {s.resource + ".default"},
+			"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+			"client_assertion":      {strings.TrimSpace(string(assertion))},
+		})
+	} else {
+		q := url.Values{"api-version": {"2018-02-01"}, "resource": {s.resource}}
+		if id := s.clientID(); id != "" {
+			q.Set("client_id", id)
+		}
+		req, _ := http.NewRequest(http.MethodGet, azureIMDSTokenURL+"?"+q.Encode(), nil)
+		req.Header.Set("Metadata", "true")
+		res, err = azureTokenClient.Do(req)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get azure access token: %s: %s", res.Status, b)
+	}
+	// the metadata service returns expires_in as a string
+	t := struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   interface{} `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return "", err
+	}
+	expiresIn, _ := strconv.Atoi(fmt.Sprint(t.ExpiresIn))
+	s.token = t.AccessToken
+	s.expiry = time.Now().Add(time.Duration(expiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+type azureMonitorSeries struct {
+	DimValues []string `json:"dimValues"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     int      `json:"count"`
+}
+
+type azureMonitorMetric struct {
+	Time string `json:"time"`
+	Data struct {
+		BaseData struct {
+			Metric    string               `json:"metric"`
+			Namespace string               `json:"namespace"`
+			DimNames  []string             `json:"dimNames"`
+			Series    []azureMonitorSeries `json:"series"`
+		} `json:"baseData"`
+	} `json:"data"`
+}
+
+func validateAzureMonitorFlags() error {
+	if *azureMonitorResourceID == "" {
+		return nil
+	}
+	if !strings.HasPrefix(*azureMonitorResourceID, "/subscriptions/") {
+		return fmt.Errorf("invalid value `%s` of flag `azureMonitorResourceID`, must start with /subscriptions/", *azureMonitorResourceID)
+	}
+	if *azureMonitorRegion == "" {
+		return fmt.Errorf("flag `azureMonitorResourceID` needs `azureMonitorRegion`")
+	}
+	return nil
+}
+
+type azureMonitorSink struct {
+	client *http.Client
+	ts     *azureTokenSource
+}
+
+func newAzureMonitorSink() *azureMonitorSink {
+	return &azureMonitorSink{
+		client: &http.Client{Timeout: 30 * time.Second},
+		ts:     &azureTokenSource{resource: azureMonitorResource},
+	}
+}
+
+func (s *azureMonitorSink) Name() string {
+	return "azuremonitor"
+}
+
+// azureMonitorDims returns the dimension names of a metric. Labels beyond the
+// limit of 10 dimensions are dropped, keeping hpa_namespace and hpa_name.
+func azureMonitorDims(labels map[string]string) []string {
+	dims := []string{"hpa_namespace", "hpa_name"}
+	rest := []string{}
+	for k := range labels {
+		if k != "hpa_namespace" && k != "hpa_name" {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	dims = append(dims, rest...)
+	if len(dims) > azureMonitorMaxDims {
+		dims = dims[:azureMonitorMaxDims]
+	}
+	return dims
+}
+
+// Write publishes one request per metric, as a custom metric has a single
+// set of dimension names.
+func (s *azureMonitorSink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	samples, err := hpaSamples()
+	if err != nil {
+		return err
+	}
+	metrics := map[string]*azureMonitorMetric{}
+	names := []string{}
+	for _, m := range samples {
+		am, ok := metrics[m.Name]
+		if !ok {
+			am = &azureMonitorMetric{Time: now.UTC().Format(time.RFC3339)}
+			am.Data.BaseData.Metric = m.Name
+			am.Data.BaseData.Namespace = *azureMonitorNamespace
+			am.Data.BaseData.DimNames = azureMonitorDims(m.Labels)
+			metrics[m.Name] = am
+			names = append(names, m.Name)
+		}
+		values := []string{}
+		for _, d := range am.Data.BaseData.DimNames {
+			values = append(values, m.Labels[d])
+		}
+		am.Data.BaseData.Series = append(am.Data.BaseData.Series, azureMonitorSeries{
+			DimValues: values,
+			Min:       m.Value,
+			Max:       m.Value,
+			Sum:       m.Value,
+			Count:     1,
+		})
+	}
+	token, err := s.ts.Token()
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("https://%s.monitoring.azure.com%s/metrics", *azureMonitorRegion, *azureMonitorResourceID)
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	for _, name := range names {
+		if err := postJSON(s.client, url, header, metrics[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var bigqueryProject = flag.String("bigqueryProject", "", "Google Cloud project of the BigQuery dataset to stream HPA state and condition changes to. Nothing is streamed when empty.")
+var bigqueryDataset = flag.String("bigqueryDataset", "", "BigQuery dataset of bigqueryTable and bigqueryConditionsTable.")
+var bigqueryTable = flag.String("bigqueryTable", defaultBigqueryTable, "BigQuery table HPA state snapshots are streamed to. It is created when it does not exist.")
+var bigqueryConditionsTable = flag.String("bigqueryConditionsTable", defaultBigqueryConditionsTable, "BigQuery table condition changes are streamed to. It is created when it does not exist.")
+var bigquerySnapshotInterval = flag.Duration("bigquerySnapshotInterval", defaultBigquerySnapshotInterval, "Interval of HPA state snapshots streamed to BigQuery.")
+
+const bigqueryAPI = "https://bigquery.googleapis.com/bigquery/v2"
+
+type bqField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+var bqStateSchema = []bqField{
+	{"time", "TIMESTAMP", "REQUIRED"},
+	{"namespace", "STRING", "REQUIRED"},
+	{"name", "STRING", "REQUIRED"},
+	{"ref_kind", "STRING", ""},
+	{"ref_name", "STRING", ""},
+	{"min_replicas", "INTEGER", ""},
+	{"max_replicas", "INTEGER", ""},
+	{"current_replicas", "INTEGER", ""},
+	{"desired_replicas", "INTEGER", ""},
+	{"demand_replicas", "FLOAT", ""},
+	{"target_metrics", "STRING", ""},
+	{"current_metrics", "STRING", ""},
+	{"conditions", "STRING", ""},
+}
+
+var bqConditionSchema = []bqField{
+	{"time", "TIMESTAMP", "REQUIRED"},
+	{"namespace", "STRING", "REQUIRED"},
+	{"name", "STRING", "REQUIRED"},
+	{"type", "STRING", "REQUIRED"},
+	{"status", "STRING", ""},
+	{"reason", "STRING", ""},
+	{"message", "STRING", ""},
+	{"last_transition_time", "TIMESTAMP", ""},
+}
+
+type bqRow struct {
+	InsertID string                 `json:"insertId"`
+	JSON     map[string]interface{} `json:"json"`
+}
+
+type bqInsertResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+type bigquerySink struct {
+	ts         *gcpTokenSource
+	tablesOK   bool
+	lastState  time.Time
+	conditions map[string]map[string]as_v2.HorizontalPodAutoscalerCondition
+}
+
+func newBigquerySink() *bigquerySink {
+	return &bigquerySink{
+		ts: newGCPTokenSource("https://www.googleapis.com/auth/bigquery.insertdata", "https://www.googleapis.com/auth/bigquery"),
+	}
+}
+
+func (s *bigquerySink) Name() string {
+	return "bigquery"
+}
+
+func (s *bigquerySink) datasetURL() string {
+	return fmt.Sprintf("%s/projects/%s/datasets/%s", bigqueryAPI, *bigqueryProject, *bigqueryDataset)
+}
+
+func (s *bigquerySink) createTable(table string, schema []bqField) error {
+	t := map[string]interface{}{
+		"tableReference": map[string]string{
+			"projectId": *bigqueryProject,
+			"datasetId": *bigqueryDataset,
+			"tableId":   table,
+		},
+		"schema":           map[string]interface{}{"fields": schema},
+		"timePartitioning": map[string]string{"type": "DAY", "field": "time"},
+	}
+	code, err := gcpDo(s.ts, http.MethodPost, s.datasetURL()+"/tables", t, nil)
+	if code == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+func (s *bigquerySink) insert(table string, rows []bqRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	res := bqInsertResponse{}
+	if _, err := gcpDo(s.ts, http.MethodPost, s.datasetURL()+"/tables/"+table+"/insertAll", map[string]interface{}{"rows": rows}, &res); err != nil {
+		return err
+	}
+	if len(res.InsertErrors) > 0 && len(res.InsertErrors[0].Errors) > 0 {
+		e := res.InsertErrors[0].Errors[0]
+		return fmt.Errorf("insert into %s: %d rows failed, first: %s: %s", table, len(res.InsertErrors), e.Reason, e.Message)
+	}
+	return nil
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func (s *bigquerySink) stateRows(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) []bqRow {
+	rows := []bqRow{}
+	for _, a := range hpa {
+		rows = append(rows, bqRow{
+			InsertID: fmt.Sprintf("%s/%d", hpaKey(a), now.Unix()),
+			JSON: map[string]interface{}{
+				"time":             now.UTC().Format(time.RFC3339Nano),
+				"namespace":        a.ObjectMeta.Namespace,
+				"name":             a.ObjectMeta.Name,
+				"ref_kind":         a.Spec.ScaleTargetRef.Kind,
+				"ref_name":         a.Spec.ScaleTargetRef.Name,
+				"min_replicas":     minReplicas(a),
+				"max_replicas":     a.Spec.MaxReplicas,
+				"current_replicas": a.Status.CurrentReplicas,
+				"desired_replicas": a.Status.DesiredReplicas,
+				"demand_replicas":  demandReplicas(a),
+				"target_metrics":   jsonString(metricsObject(specMetrics(a))),
+				"current_metrics":  jsonString(metricsObject(statusMetrics(a))),
+				"conditions":       jsonString(redactConditions(a.Status.Conditions)),
+			},
+		})
+	}
+	return rows
+}
+
+// bqConditionInsertID identifies a condition change for the best effort
+// deduplication of BigQuery, so an insert retried after an error does not
+// add the row twice, while a change of reason or message without a new
+// transition gets a row of its own.
+func bqConditionInsertID(a as_v2.HorizontalPodAutoscaler, c as_v2.HorizontalPodAutoscalerCondition) string {
+	h := sha256.Sum256([]byte(string(c.Status) + "\x00" + c.Reason + "\x00" + redact(c.Message)))
+	return fmt.Sprintf("%s/%s/%d/%s", hpaKey(a), c.Type, c.LastTransitionTime.Unix(), hex.EncodeToString(h[:8]))
+}
+
+// conditionRows returns a row for every condition that changed since the
+// conditions last streamed, and the conditions to remember once the rows are
+// inserted. Conditions of HPAs seen for the first time are remembered
+// without being streamed.
+func (s *bigquerySink) conditionRows(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) ([]bqRow, map[string]map[string]as_v2.HorizontalPodAutoscalerCondition) {
+	first := s.conditions == nil
+	seen := map[string]map[string]as_v2.HorizontalPodAutoscalerCondition{}
+	rows := []bqRow{}
+	for _, a := range hpa {
+		prev, known := s.conditions[hpaKey(a)]
+		cur := map[string]as_v2.HorizontalPodAutoscalerCondition{}
+		for _, c := range a.Status.Conditions {
+			cur[string(c.Type)] = c
+			p, ok := prev[string(c.Type)]
+			if first || !known || (ok && p.Status == c.Status && p.Reason == c.Reason && p.Message == c.Message) {
+				continue
+			}
+			rows = append(rows, bqRow{
+				InsertID: bqConditionInsertID(a, c),
+				JSON: map[string]interface{}{
+					"time":                 now.UTC().Format(time.RFC3339Nano),
+					"namespace":            a.ObjectMeta.Namespace,
+					"name":                 a.ObjectMeta.Name,
+					"type":                 string(c.Type),
+					"status":               string(c.Status),
+					"reason":               c.Reason,
+					"message":              redact(c.Message),
+					"last_transition_time": c.LastTransitionTime.UTC().Format(time.RFC3339),
+				},
+			})
+		}
+		seen[hpaKey(a)] = cur
+	}
+	return rows, seen
+}
+
+func (s *bigquerySink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	if !s.tablesOK {
+		if err := s.createTable(*bigqueryTable, bqStateSchema); err != nil {
+			return err
+		}
+		if err := s.createTable(*bigqueryConditionsTable, bqConditionSchema); err != nil {
+			return err
+		}
+		s.tablesOK = true
+	}
+	rows, conditions := s.conditionRows(hpa, now)
+	if err := s.insert(*bigqueryConditionsTable, rows); err != nil {
+		return err
+	}
+	s.conditions = conditions
+	if now.Sub(s.lastState) < *bigquerySnapshotInterval {
+		return nil
+	}
+	if err := s.insert(*bigqueryTable, s.stateRows(hpa, now)); err != nil {
+		return err
+	}
+	s.lastState = now
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var checkCapacity = flag.Bool("checkCapacity", defaultCheckCapacity, "Compare the requests every HPA would add by scaling to maxReplicas with the unrequested allocatable capacity of the cluster. Needs `get` on the scale targets and `list` on nodes and pods of all namespaces.")
+
+// unrequestedCapacity returns the allocatable CPU cores and memory bytes of
+// the schedulable nodes less the requests of the pods running on them.
+func unrequestedCapacity() (podResources, error) {
+	ret := podResources{}
+	nodes, err := kubeClient.CoreV1().Nodes().List(meta_v1.ListOptions{})
+	if err != nil {
+		return ret, err
+	}
+	schedulable := map[string]bool{}
+	for _, n := range nodes.Items {
+		if n.Spec.Unschedulable {
+			continue
+		}
+		schedulable[n.ObjectMeta.Name] = true
+		ret.CPURequest += float64(n.Status.Allocatable.Cpu().MilliValue()) / 1000
+		ret.MemoryRequest += float64(n.Status.Allocatable.Memory().Value())
+	}
+	pods, err := kubeClient.CoreV1().Pods("").List(meta_v1.ListOptions{})
+	if err != nil {
+		return ret, err
+	}
+	for _, p := range pods.Items {
+		if !schedulable[p.Spec.NodeName] || p.Status.Phase == core_v1.PodSucceeded || p.Status.Phase == core_v1.PodFailed {
+			continue
+		}
+		r := podTemplateResources(p.Spec)
+		ret.CPURequest -= r.CPURequest
+		ret.MemoryRequest -= r.MemoryRequest
+	}
+	return ret, nil
+}
+
+// maxScaleRequests returns the CPU cores and memory bytes the pods of the
+// HPAs would additionally request if all of them scaled to maxReplicas.
+func maxScaleRequests(hpa []as_v2.HorizontalPodAutoscaler) podResources {
+	ret := podResources{}
+	for _, a := range hpa {
+		n := a.Spec.MaxReplicas - a.Status.CurrentReplicas
+		if n <= 0 {
+			continue
+		}
+		t, err := cachedScaleTarget(a)
+		if err != nil || t.Subresource {
+			continue
+		}
+		ret.CPURequest += float64(n) * t.PodResources.CPURequest
+		ret.MemoryRequest += float64(n) * t.PodResources.MemoryRequest
+	}
+	return ret
+}
+
+func detectCapacityGap(hpa []as_v2.HorizontalPodAutoscaler) error {
+	free, err := unrequestedCapacity()
+	if err != nil {
+		return err
+	}
+	need := maxScaleRequests(hpa)
+	hpaMaxScaleRequests.WithLabelValues("cpu").Set(need.CPURequest)
+	hpaMaxScaleRequests.WithLabelValues("memory").Set(need.MemoryRequest)
+	hpaMaxScaleCapacityGap.WithLabelValues("cpu").Set(need.CPURequest - free.CPURequest)
+	hpaMaxScaleCapacityGap.WithLabelValues("memory").Set(need.MemoryRequest - free.MemoryRequest)
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/common/log"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+// hpaChange is a condition change or a change of desired replicas of an HPA,
+// as published by the event sinks.
+type hpaChange struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+
+	// set for "condition"
+	Condition       string `json:"condition,omitempty"`
+	Status          string `json:"status,omitempty"`
+	PreviousStatus  string `json:"previousStatus,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	Message         string `json:"message,omitempty"`
+	CurrentReplicas int32  `json:"currentReplicas"`
+
+	// set for "scale"
+	FromReplicas int32 `json:"fromReplicas,omitempty"`
+	ToReplicas   int32 `json:"toReplicas,omitempty"`
+}
+
+const (
+	changeCondition = "condition"
+	changeScale     = "scale"
+)
+
+type trackedHpa struct {
+	desired    int32
+	conditions map[string]as_v2.HorizontalPodAutoscalerCondition
+}
+
+// maxUnsentChanges bounds the changes kept while a sink fails.
+const maxUnsentChanges = 10000
+
+// changeTracker remembers the HPAs of the previous collection to tell what
+// changed. HPAs seen for the first time, including all HPAs of the first
+// collection, have no changes.
+type changeTracker struct {
+	prev map[string]trackedHpa
+	// unsent are the changes returned by changes that were not marked sent,
+	// returned again first.
+	unsent []hpaChange
+}
+
+// sent marks the first n changes returned by changes as published.
+func (t *changeTracker) sent(n int) {
+	t.unsent = t.unsent[n:]
+	if len(t.unsent) == 0 {
+		t.unsent = nil
+	}
+}
+
+// changes returns the changes not yet sent, those of earlier collections
+// first. The sink calls sent with the number it published.
+func (t *changeTracker) changes(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) []hpaChange {
+	ret := t.diff(hpa, now)
+	if len(t.unsent) > 0 {
+		ret = append(t.unsent, ret...)
+	}
+	if len(ret) > maxUnsentChanges {
+		log.Errorf("dropped %d unsent HPA changes", len(ret)-maxUnsentChanges)
+		ret = ret[len(ret)-maxUnsentChanges:]
+	}
+	t.unsent = ret
+	return ret
+}
+
+func (t *changeTracker) diff(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) []hpaChange {
+	cur := map[string]trackedHpa{}
+	ret := []hpaChange{}
+	for _, a := range hpa {
+		c := trackedHpa{desired: a.Status.DesiredReplicas, conditions: map[string]as_v2.HorizontalPodAutoscalerCondition{}}
+		for _, cond := range a.Status.Conditions {
+			c.conditions[string(cond.Type)] = cond
+		}
+		cur[hpaKey(a)] = c
+		p, ok := t.prev[hpaKey(a)]
+		if !ok {
+			continue
+		}
+		base := hpaChange{
+			Time:            now,
+			Namespace:       a.ObjectMeta.Namespace,
+			Name:            a.ObjectMeta.Name,
+			CurrentReplicas: a.Status.CurrentReplicas,
+		}
+		if p.desired != c.desired {
+			e := base
+			e.Type = changeScale
+			e.FromReplicas = p.desired
+			e.ToReplicas = c.desired
+			ret = append(ret, e)
+		}
+		for _, cond := range a.Status.Conditions {
+			pc := p.conditions[string(cond.Type)]
+			if pc.Status == cond.Status && pc.Reason == cond.Reason {
+				continue
+			}
+			e := base
+			e.Type = changeCondition
+			e.Condition = string(cond.Type)
+			e.Status = string(cond.Status)
+			e.PreviousStatus = string(pc.Status)
+			e.Reason = cond.Reason
+			e.Message = redact(cond.Message)
+			ret = append(ret, e)
+		}
+	}
+	t.prev = cur
+	return ret
+}
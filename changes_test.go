@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var testTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func trackerHpa(desired int32) []as_v2.HorizontalPodAutoscaler {
+	a := as_v2.HorizontalPodAutoscaler{}
+	a.ObjectMeta.Namespace, a.ObjectMeta.Name = "prod", "web"
+	a.Status.DesiredReplicas = desired
+	return []as_v2.HorizontalPodAutoscaler{a}
+}
+
+func TestChangeTrackerUnsent(t *testing.T) {
+	tr := changeTracker{}
+	now := testTime
+	if cs := tr.changes(trackerHpa(2), now); len(cs) != 0 {
+		t.Fatalf("first collection has changes: %v", cs)
+	}
+	tr.sent(0)
+	cs := tr.changes(trackerHpa(3), now)
+	if len(cs) != 1 || cs[0].FromReplicas != 2 || cs[0].ToReplicas != 3 {
+		t.Fatalf("got %v, want a scale from 2 to 3", cs)
+	}
+	// the publish failed
+	tr.sent(0)
+	cs = tr.changes(trackerHpa(4), now)
+	if len(cs) != 2 || cs[0].ToReplicas != 3 || cs[1].ToReplicas != 4 {
+		t.Fatalf("got %v, want the unsent scale to 3 and the scale to 4", cs)
+	}
+	// only the first was published
+	tr.sent(1)
+	cs = tr.changes(trackerHpa(4), now)
+	if len(cs) != 1 || cs[0].ToReplicas != 4 {
+		t.Fatalf("got %v, want the unsent scale to 4", cs)
+	}
+	tr.sent(len(cs))
+	if cs := tr.changes(trackerHpa(4), now); len(cs) != 0 {
+		t.Fatalf("got %v, want no changes", cs)
+	}
+}
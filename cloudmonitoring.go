@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var gcmProject = flag.String("gcmProject", "", "Google Cloud project to write the HPA metrics to as Cloud Monitoring custom metrics. Nothing is written when empty.")
+var gcmLocation = flag.String("gcmLocation", "", "Location of the GKE cluster for the k8s_cluster resource labels. Read from the metadata server when empty.")
+var gcmClusterName = flag.String("gcmClusterName", "", "Name of the GKE cluster for the k8s_cluster resource labels. Read from the metadata server when empty.")
+var gcmMetricPrefix = flag.String("gcmMetricPrefix", defaultGcmMetricPrefix, "Prefix of the Cloud Monitoring metric types.")
+
+const (
+	cloudMonitoringAPI = "https://monitoring.googleapis.com/v3"
+	// timeSeries.create takes at most 200 time series per request
+	gcmBatchSize = 200
+)
+
+type gcmTimeSeries struct {
+	Metric struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metric"`
+	Resource struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+	Points []gcmPoint `json:"points"`
+}
+
+type gcmPoint struct {
+	Interval struct {
+		EndTime string `json:"endTime"`
+	} `json:"interval"`
+	Value struct {
+		DoubleValue float64 `json:"doubleValue"`
+	} `json:"value"`
+}
+
+type cloudMonitoringSink struct {
+	ts       *gcpTokenSource
+	resource map[string]string
+}
+
+func newCloudMonitoringSink() *cloudMonitoringSink {
+	return &cloudMonitoringSink{
+		ts: newGCPTokenSource("https://www.googleapis.com/auth/monitoring.write"),
+	}
+}
+
+func (s *cloudMonitoringSink) Name() string {
+	return "cloudmonitoring"
+}
+
+// resourceLabels returns the labels of the k8s_cluster monitored resource,
+// reading the ones not given by flags from the metadata server.
+func (s *cloudMonitoringSink) resourceLabels() (map[string]string, error) {
+	if s.resource != nil {
+		return s.resource, nil
+	}
+	location, cluster := *gcmLocation, *gcmClusterName
+	var err error
+	if location == "" {
+		if location, err = gcpMetadata("instance/attributes/cluster-location"); err != nil {
+			return nil, fmt.Errorf("gcmLocation is not set: %v", err)
+		}
+	}
+	if cluster == "" {
+		if cluster, err = gcpMetadata("instance/attributes/cluster-name"); err != nil {
+			return nil, fmt.Errorf("gcmClusterName is not set: %v", err)
+		}
+	}
+	s.resource = map[string]string{
+		"project_id":   *gcmProject,
+		"location":     location,
+		"cluster_name": cluster,
+	}
+	return s.resource, nil
+}
+
+func (s *cloudMonitoringSink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	resource, err := s.resourceLabels()
+	if err != nil {
+		return err
+	}
+	samples, err := hpaSamples()
+	if err != nil {
+		return err
+	}
+	series := []gcmTimeSeries{}
+	for _, m := range samples {
+		ts := gcmTimeSeries{}
+		ts.Metric.Type = *gcmMetricPrefix + m.Name
+		ts.Metric.Labels = m.Labels
+		ts.Resource.Type = "k8s_cluster"
+		ts.Resource.Labels = resource
+		p := gcmPoint{}
+		p.Interval.EndTime = now.UTC().Format(time.RFC3339Nano)
+		p.Value.DoubleValue = m.Value
+		ts.Points = []gcmPoint{p}
+		series = append(series, ts)
+	}
+	url := fmt.Sprintf("%s/projects/%s/timeSeries", cloudMonitoringAPI, *gcmProject)
+	for len(series) > 0 {
+		n := len(series)
+		if n > gcmBatchSize {
+			n = gcmBatchSize
+		}
+		if _, err := gcpDo(s.ts, http.MethodPost, url, map[string]interface{}{"timeSeries": series[:n]}, nil); err != nil {
+			return err
+		}
+		series = series[n:]
+	}
+	return nil
+}
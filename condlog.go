@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var conditionLogFormat = flag.String("conditionLogFormat", defaultConditionLogFormat, "Log the conditions of an HPA as one event (nested) or each condition as its own event with top-level fields (flat), which is easier to query in CloudWatch Logs Insights.")
+
+// flatCondition is the event of a condition with conditionLogFormat=flat.
+// The previous_* fields are set for conditions that changed with
+// conditionLogChangesOnly.
+type flatCondition struct {
+	HPAName                    string `json:"hpa_name"`
+	Namespace                  string `json:"namespace"`
+	ConditionType              string `json:"condition_type"`
+	Status                     string `json:"status"`
+	Reason                     string `json:"reason"`
+	Message                    string `json:"message"`
+	LastTransitionTime         string `json:"last_transition_time,omitempty"`
+	PreviousStatus             string `json:"previous_status,omitempty"`
+	PreviousReason             string `json:"previous_reason,omitempty"`
+	PreviousMessage            string `json:"previous_message,omitempty"`
+	PreviousLastTransitionTime string `json:"previous_last_transition_time,omitempty"`
+}
+
+func validateConditionLogFormat() error {
+	if !(*conditionLogFormat == "nested" || *conditionLogFormat == "flat") {
+		return fmt.Errorf("invalid value `%s` of flag `conditionLogFormat`, specify either `nested` or `flat`", *conditionLogFormat)
+	}
+	return nil
+}
+
+// conditionLogLines returns the events to log for the conditions of the
+// HPAs.
+func conditionLogLines(hpa []as_v2.HorizontalPodAutoscaler) []string {
+	ret := []string{}
+	for _, a := range hpa {
+		if *conditionLogFormat != "flat" {
+			ret = append(ret, hpaConditionLogString(a))
+			continue
+		}
+		for _, c := range flatConditions(a) {
+			b, err := json.Marshal(c)
+			if err != nil {
+				fmt.Println("JSON Marshal error:", err)
+				continue
+			}
+			ret = append(ret, string(b))
+		}
+	}
+	return ret
+}
+
+// flatConditions returns the conditions of the HPA, only those that changed
+// with conditionLogChangesOnly.
+func flatConditions(a as_v2.HorizontalPodAutoscaler) []flatCondition {
+	ret := []flatCondition{}
+	if *conditionLogChangesOnly {
+		for _, ch := range conditionChanges(a) {
+			if ch.Current == nil {
+				continue
+			}
+			f := newFlatCondition(a, *ch.Current)
+			if ch.Previous != nil {
+				f.PreviousStatus = string(ch.Previous.Status)
+				f.PreviousReason = ch.Previous.Reason
+				f.PreviousMessage = ch.Previous.Message
+				f.PreviousLastTransitionTime = transitionTime(ch.Previous.LastTransitionTime)
+			}
+			ret = append(ret, f)
+		}
+		return ret
+	}
+	for _, c := range redactConditions(a.Status.Conditions) {
+		ret = append(ret, newFlatCondition(a, c))
+	}
+	return ret
+}
+
+func newFlatCondition(a as_v2.HorizontalPodAutoscaler, c as_v2.HorizontalPodAutoscalerCondition) flatCondition {
+	f := flatCondition{
+		HPAName:       a.ObjectMeta.Name,
+		Namespace:     a.ObjectMeta.Namespace,
+		ConditionType: string(c.Type),
+		Status:        string(c.Status),
+		Reason:        c.Reason,
+		Message:       c.Message,
+	}
+	f.LastTransitionTime = transitionTime(c.LastTransitionTime)
+	return f
+}
+
+func transitionTime(t meta_v1.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
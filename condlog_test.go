@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFlatConditionsPrevious(t *testing.T) {
+	defer setFlagValues(flagValues())
+	defer markConditionsLogged(nil)
+	*conditionLogChangesOnly = true
+	a := as_v2.HorizontalPodAutoscaler{}
+	a.ObjectMeta.Namespace, a.ObjectMeta.Name = "prod", "web"
+	a.Status.Conditions = []as_v2.HorizontalPodAutoscalerCondition{{
+		Type:               as_v2.ScalingActive,
+		Status:             core_v1.ConditionTrue,
+		Reason:             "ValidMetricFound",
+		LastTransitionTime: meta_v1.NewTime(testTime),
+	}}
+	markConditionsLogged([]as_v2.HorizontalPodAutoscaler{a})
+
+	a.Status.Conditions[0].Status = core_v1.ConditionFalse
+	a.Status.Conditions[0].Reason = "FailedGetResourceMetric"
+	a.Status.Conditions[0].LastTransitionTime = meta_v1.NewTime(testTime.Add(time.Minute))
+	fs := flatConditions(a)
+	if len(fs) != 1 {
+		t.Fatalf("got %d conditions, want the changed one", len(fs))
+	}
+	f := fs[0]
+	if f.LastTransitionTime != "2020-01-02T03:05:05Z" || f.PreviousLastTransitionTime != "2020-01-02T03:04:05Z" {
+		t.Errorf("transition times %s, previous %s", f.LastTransitionTime, f.PreviousLastTransitionTime)
+	}
+	if f.PreviousStatus != "True" || f.PreviousReason != "ValidMetricFound" {
+		t.Errorf("previous status %s, reason %s", f.PreviousStatus, f.PreviousReason)
+	}
+}
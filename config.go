@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v2"
+)
+
+var configFile = flag.String("config", "", "Path to a YAML file of flag values. Flags given on the command line take precedence.")
+
+// commandLineFlags holds the names of flags set on the command line,
+// which the config file must not override.
+var commandLineFlags = map[string]bool{}
+
+// parsedFlagValues are the values of the flags before the config file is
+// applied, which flags removed from the file return to on reload.
+var parsedFlagValues = map[string]string{}
+
+// configFileFlags holds the names of flags set by the config file on start.
+var configFileFlags = map[string]bool{}
+
+// secretFlags are redacted wherever the configuration is displayed.
+// Flags registered with newSecret are added to it.
+var secretFlags = map[string]bool{
+	"kube-proxy-url": true,
+}
+
+func flagValues() map[string]string {
+	ret := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		ret[f.Name] = f.Value.String()
+	})
+	return ret
+}
+
+func explicitFlags() map[string]bool {
+	ret := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		ret[f.Name] = true
+	})
+	return ret
+}
+
+// reloadFlags are the flags a reload can change, the filters of HPAs and of
+// condition messages. They are read through currentAppliedConfig. Other
+// flags are read without locks, so changing them needs a restart.
+var reloadFlags = map[string]bool{
+	"redactPattern":     true,
+	"redactReplacement": true,
+	"include-hpa-regex": true,
+	"exclude-hpa-regex": true,
+}
+
+// appliedConfig is the configuration of reloadFlags in use. It is not
+// modified once published.
+type appliedConfig struct {
+	values   map[string]string
+	fromFile map[string]bool
+
+	redact           *regexp.Regexp
+	include, exclude *regexp.Regexp
+}
+
+var appliedConfigValue atomic.Value
+
+func currentAppliedConfig() *appliedConfig {
+	if c, ok := appliedConfigValue.Load().(*appliedConfig); ok {
+		return c
+	}
+	return &appliedConfig{values: map[string]string{}, fromFile: map[string]bool{}}
+}
+
+func newAppliedConfig(values map[string]string, fromFile map[string]bool) (*appliedConfig, error) {
+	c := &appliedConfig{values: values, fromFile: fromFile}
+	var err error
+	if c.redact, err = compileRedactPattern(values["redactPattern"]); err != nil {
+		return nil, fmt.Errorf("invalid value `%s` of flag `redactPattern`: %v", values["redactPattern"], err)
+	}
+	if c.include, c.exclude, err = compileHpaRegexes(values["include-hpa-regex"], values["exclude-hpa-regex"]); err != nil {
+		return nil, fmt.Errorf("invalid value of flag `include-hpa-regex` or `exclude-hpa-regex`: %v", err)
+	}
+	return c, nil
+}
+
+// loadAppliedConfig publishes reloadFlags as set on start.
+func loadAppliedConfig() error {
+	values := map[string]string{}
+	for name := range reloadFlags {
+		values[name] = flag.Lookup(name).Value.String()
+	}
+	fromFile := map[string]bool{}
+	for name := range configFileFlags {
+		fromFile[name] = true
+	}
+	c, err := newAppliedConfig(values, fromFile)
+	if err != nil {
+		return err
+	}
+	appliedConfigValue.Store(c)
+	return nil
+}
+
+// reloadedConfig reads the config file again. Flags not in reloadFlags
+// must keep their values, also those removed from the file, which return
+// to parsedFlagValues.
+func reloadedConfig(path string) (*appliedConfig, error) {
+	file, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	fromFile := map[string]bool{}
+	flag.VisitAll(func(f *flag.Flag) {
+		v, ok := parsedFlagValues[f.Name]
+		if !ok {
+			v = f.DefValue
+		}
+		if fv, ok := file[f.Name]; ok && !commandLineFlags[f.Name] {
+			v = fv
+			fromFile[f.Name] = true
+		}
+		if reloadFlags[f.Name] {
+			values[f.Name] = v
+			return
+		}
+		if err == nil && v != f.Value.String() {
+			err = fmt.Errorf("flag `%s` cannot be changed by a reload, restart to apply it", f.Name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newAppliedConfig(values, fromFile)
+}
+
+func loadConfigFile(path string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for name, v := range values {
+		if commandLineFlags[name] {
+			continue
+		}
+		if err := flag.Set(name, v); err != nil {
+			return fmt.Errorf("invalid value of flag `%s` in config file %s: %v", name, path, err)
+		}
+		configFileFlags[name] = true
+	}
+	return nil
+}
+
+// readConfigFile returns the flag values of the config file, in the form
+// the flags print them.
+func readConfigFile(path string) (map[string]string, error) {
+	ret := map[string]string{}
+	if path == "" {
+		return ret, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %v", path, err)
+	}
+	for name, v := range values {
+		f := flag.Lookup(name)
+		if f == nil {
+			return nil, fmt.Errorf("unknown flag `%s` in config file %s", name, path)
+		}
+		// a new value of the type of the flag parses v without setting it
+		nv := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(flag.Value)
+		if err := nv.Set(configValueString(v)); err != nil {
+			return nil, fmt.Errorf("invalid value of flag `%s` in config file %s: %v", name, path, err)
+		}
+		ret[name] = nv.String()
+	}
+	return ret, nil
+}
+
+func configValueString(v interface{}) string {
+	if l, ok := v.([]interface{}); ok {
+		s := make([]string, 0, len(l))
+		for _, e := range l {
+			s = append(s, fmt.Sprint(e))
+		}
+		return strings.Join(s, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+func parseKeyValues(s string) map[string]string {
+	ret := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		p := strings.SplitN(kv, "=", 2)
+		if len(p) == 2 {
+			ret[p[0]] = p[1]
+		} else {
+			ret[p[0]] = ""
+		}
+	}
+	return ret
+}
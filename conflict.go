@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+
+	"github.com/prometheus/client_golang/prometheus"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var checkControllerConflict = flag.Bool("checkControllerConflict", defaultCheckControllerConflict, "List KEDA ScaledObjects and report HPAs whose scale target is also managed by a ScaledObject that does not own the HPA.")
+
+const (
+	scaledObjectsPath          = "/apis/keda.sh/v1alpha1/scaledobjects"
+	scaledObjectsNamespacePath = "/apis/keda.sh/v1alpha1/namespaces/"
+	kedaNameLabel              = "scaledobject.keda.sh/name"
+)
+
+type scaledObject struct {
+	Metadata meta_v1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		ScaleTargetRef struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"scaleTargetRef"`
+	} `json:"spec"`
+}
+
+type scaledObjectList struct {
+	Items []scaledObject `json:"items"`
+}
+
+// listScaledObjects returns no ScaledObjects when KEDA is not installed.
+func listScaledObjects() ([]scaledObject, error) {
+	ret := []scaledObject{}
+	for _, ns := range listedNamespaces() {
+		p := scaledObjectsPath
+		if ns != "" {
+			p = scaledObjectsNamespacePath + ns + "/scaledobjects"
+		}
+		b, err := kubeClient.Discovery().RESTClient().Get().AbsPath(p).DoRaw()
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		l := scaledObjectList{}
+		if err := json.Unmarshal(b, &l); err != nil {
+			return nil, err
+		}
+		ret = append(ret, l.Items...)
+	}
+	return ret, nil
+}
+
+// ownedByScaledObject tells whether the HPA was created by the
+// ScaledObject, which is how KEDA drives scaling.
+func ownedByScaledObject(a as_v2.HorizontalPodAutoscaler, so scaledObject) bool {
+	for _, o := range a.ObjectMeta.OwnerReferences {
+		if o.Kind == "ScaledObject" && o.Name == so.Metadata.Name {
+			return true
+		}
+	}
+	return a.ObjectMeta.Labels[kedaNameLabel] == so.Metadata.Name
+}
+
+func detectControllerConflicts(hpa []as_v2.HorizontalPodAutoscaler) error {
+	sos, err := listScaledObjects()
+	if err != nil {
+		return err
+	}
+	for _, a := range hpa {
+		for _, so := range sos {
+			kind := so.Spec.ScaleTargetRef.Kind
+			if kind == "" {
+				kind = "Deployment"
+			}
+			if so.Metadata.Namespace != a.ObjectMeta.Namespace ||
+				kind != a.Spec.ScaleTargetRef.Kind ||
+				so.Spec.ScaleTargetRef.Name != a.Spec.ScaleTargetRef.Name ||
+				ownedByScaledObject(a, so) {
+				continue
+			}
+			hpaControllerConflict.With(mergeLabels(hpaBaseLabels(a), prometheus.Labels{
+				"controller":      "keda",
+				"controller_name": so.Metadata.Name,
+			})).Set(1)
+		}
+	}
+	return nil
+}
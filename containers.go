@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+
+	"github.com/prometheus/client_golang/prometheus"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var containerMetrics = flag.Bool("containerMetrics", defaultContainerMetrics, "Query metrics.k8s.io for the pods of HPAs with Resource metrics and expose the usage of each container. Needs `get` on the scale targets and `list` on pods.metrics.k8s.io.")
+
+const podMetricsPath = "/apis/metrics.k8s.io/v1beta1"
+
+type podMetricsList struct {
+	Items []struct {
+		Containers []struct {
+			Name  string               `json:"name"`
+			Usage core_v1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+func getPodMetrics(ns, selector string) (*podMetricsList, error) {
+	b, err := kubeClient.Discovery().RESTClient().Get().AbsPath(podMetricsPath, "namespaces", ns, "pods").Param("labelSelector", selector).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	l := &podMetricsList{}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func quantityValue(q resource.Quantity, name core_v1.ResourceName) float64 {
+	if name == core_v1.ResourceCPU {
+		return float64(q.MilliValue()) / 1000
+	}
+	return float64(q.Value())
+}
+
+// containerResourceMetrics breaks the Resource metrics of the HPA down by
+// container, so that the container driving the pod average can be told.
+// Utilization is computed like the HPA controller does, as the sum of the
+// usage over the sum of the requests.
+func containerResourceMetrics(a as_v2.HorizontalPodAutoscaler, baseLabel prometheus.Labels) error {
+	resources := []core_v1.ResourceName{}
+	for _, m := range a.Spec.Metrics {
+		if m.Type == as_v2.ResourceMetricSourceType {
+			resources = append(resources, m.Resource.Name)
+		}
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	t, err := cachedScaleTarget(a)
+	if err != nil {
+		return err
+	}
+	l, err := getPodMetrics(a.ObjectMeta.Namespace, t.Selector)
+	if err != nil {
+		return err
+	}
+	for _, r := range resources {
+		usage := map[string]float64{}
+		pods := map[string]float64{}
+		for _, p := range l.Items {
+			for _, c := range p.Containers {
+				q, ok := c.Usage[r]
+				if !ok {
+					continue
+				}
+				usage[c.Name] += quantityValue(q, r)
+				pods[c.Name]++
+			}
+		}
+		for name, u := range usage {
+			labels := mergeLabels(baseLabel, prometheus.Labels{"resource": string(r), "container": name})
+			hpaContainerResourceUsage.With(labels).Set(u / pods[name])
+			req, ok := t.ContainerRequests[name][r]
+			if !ok || req.IsZero() {
+				continue
+			}
+			hpaContainerResourceUtilization.With(labels).Set(100 * u / (quantityValue(req, r) * pods[name]))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a standard five field cron expression: minute, hour, day
+// of month, month and day of week, each `*`, a value, a range `a-b`, a step
+// `*/n` or `a-b/n`, or a comma separated list of these.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	f := strings.Fields(expr)
+	if len(f) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression `%s` must have 5 fields", expr)
+	}
+	bits := make([]uint64, len(f))
+	for i, s := range f {
+		b, err := parseCronField(s, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s `%s` in cron expression `%s`: %v", cronFields[i].name, s, expr, err)
+		}
+		bits[i] = b
+	}
+	// 7 is also Sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: f[2] == "*",
+		dowAny: f[4] == "*",
+	}, nil
+}
+
+func parseCronField(s string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step `%s`", part[i+1:])
+			}
+			step = n
+			part = part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches reports whether the schedule fires in the minute of t. As in
+// cron, when both day of month and day of week are restricted, either one
+// matching is enough.
+func (c *cronSchedule) Matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// cronWindow is a recurring window which opens when its schedule fires and
+// stays open for Duration, e.g. `0 22 * * 1-5` for 8h on weeknights.
+type cronWindow struct {
+	Schedule string        `yaml:"schedule" json:"schedule"`
+	Duration time.Duration `yaml:"duration" json:"duration"`
+	Timezone string        `yaml:"timezone" json:"timezone,omitempty"`
+
+	cron *cronSchedule
+	loc  *time.Location
+}
+
+// exporterLocation is the time zone of the logs and of the schedules without
+// a timezone. It is resolved once, so a schedule means the same before and
+// after main sets time.Local to it.
+var exporterLocation = loadExporterLocation()
+
+func loadExporterLocation() *time.Location {
+	l, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		return time.FixedZone("Asia/Tokyo", 9*60*60)
+	}
+	return l
+}
+
+// maxCronWindow bounds the minutes Active looks back.
+const maxCronWindow = 7 * 24 * time.Hour
+
+func (w *cronWindow) compile() error {
+	c, err := parseCron(w.Schedule)
+	if err != nil {
+		return err
+	}
+	if w.Duration <= 0 || w.Duration > maxCronWindow {
+		return fmt.Errorf("duration of schedule `%s` must be between 1m and %s", w.Schedule, maxCronWindow)
+	}
+	w.loc = exporterLocation
+	if w.Timezone != "" {
+		if w.loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return err
+		}
+	}
+	w.cron = c
+	return nil
+}
+
+// Active reports whether the schedule fired within Duration before now.
+func (w *cronWindow) Active(now time.Time) bool {
+	t := now.In(w.loc).Truncate(time.Minute)
+	for end := now.Add(-w.Duration); t.After(end); t = t.Add(-time.Minute) {
+		if w.cron.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max int
+		want     uint64
+		wantErr  bool
+	}{
+		{"*", 0, 3, 0xf, false},
+		{"2", 0, 59, 1 << 2, false},
+		{"1-3", 0, 59, 0xe, false},
+		{"*/15", 0, 59, 1 | 1<<15 | 1<<30 | 1<<45, false},
+		{"10/20", 0, 59, 1<<10 | 1<<30 | 1<<50, false},
+		{"1-5/2", 0, 59, 1<<1 | 1<<3 | 1<<5, false},
+		{"1,3,5", 0, 59, 1<<1 | 1<<3 | 1<<5, false},
+		{"0", 1, 31, 0, true},
+		{"60", 0, 59, 0, true},
+		{"5-1", 0, 59, 0, true},
+		{"*/0", 0, 59, 0, true},
+		{"a", 0, 59, 0, true},
+		{"1-b", 0, 59, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCronField(tt.in, tt.min, tt.max)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %b, want %b", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"* * * *", "* * * * * *", "61 * * * *", "* * 0 * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("%s: want an error", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2020-01-06 is a Monday
+	at := func(day, hour, min int) time.Time { return time.Date(2020, 1, day, hour, min, 0, 0, time.UTC) }
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"0 22 * * 1-5", at(6, 22, 0), true},
+		{"0 22 * * 1-5", at(6, 22, 1), false},
+		{"0 22 * * 1-5", at(5, 22, 0), false},
+		{"0 0 * * 7", at(5, 0, 0), true},
+		{"0 0 * * 0", at(5, 0, 0), true},
+		// day of month or day of week when both are restricted
+		{"0 0 1 * 1", at(6, 0, 0), true},
+		{"0 0 1 * 1", at(1, 0, 0), true},
+		{"0 0 1 * 1", at(2, 0, 0), false},
+		{"0 0 6 * *", at(6, 0, 0), true},
+		{"0 0 * 2 *", at(6, 0, 0), false},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Matches(tt.t); got != tt.want {
+			t.Errorf("%s at %s: got %v, want %v", tt.expr, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestCronWindowActive(t *testing.T) {
+	w := &cronWindow{Schedule: "0 22 * * *", Duration: 8 * time.Hour, Timezone: "Europe/Berlin"}
+	if err := w.compile(); err != nil {
+		t.Fatal(err)
+	}
+	// 22:00 in Berlin is 21:00 UTC in winter
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2020, 1, 6, 20, 59, 0, 0, time.UTC), false},
+		{time.Date(2020, 1, 6, 21, 0, 0, 0, time.UTC), true},
+		{time.Date(2020, 1, 7, 4, 59, 0, 0, time.UTC), true},
+		{time.Date(2020, 1, 7, 5, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := w.Active(tt.t); got != tt.want {
+			t.Errorf("at %s: got %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+// TestCronWindowLocation checks that a schedule without a timezone does
+// not move when time.Local changes, as it does in main.
+func TestCronWindowLocation(t *testing.T) {
+	defer func(l *time.Location) { time.Local = l }(time.Local)
+	now := time.Date(2020, 1, 6, 13, 30, 0, 0, time.UTC)
+	active := []bool{}
+	for _, l := range []*time.Location{time.UTC, time.FixedZone("X", -5*60*60)} {
+		time.Local = l
+		w := &cronWindow{Schedule: "0 22 * * *", Duration: time.Hour}
+		if err := w.compile(); err != nil {
+			t.Fatal(err)
+		}
+		active = append(active, w.Active(now))
+	}
+	// 22:30 in Asia/Tokyo
+	if !active[0] || !active[1] {
+		t.Fatalf("got %v, want the window active in Asia/Tokyo regardless of time.Local", active)
+	}
+}
+
+func TestCronWindowCompile(t *testing.T) {
+	for _, w := range []*cronWindow{
+		{Schedule: "0 22 * * *"},
+		{Schedule: "0 22 * * *", Duration: 8 * 24 * time.Hour},
+		{Schedule: "0 22 * * *", Duration: time.Hour, Timezone: "Nowhere/Else"},
+	} {
+		if err := w.compile(); err == nil {
+			t.Errorf("%+v: want an error", w)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/buildsville/hpa-exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var crossCheckMetrics = flag.Bool("crossCheckMetrics", defaultCrossCheckMetrics, "Query custom.metrics.k8s.io and external.metrics.k8s.io for the Object, Pods and External metrics of each HPA and expose the adapter values.")
+
+const (
+	customMetricsPath   = "/apis/custom.metrics.k8s.io/v1beta1"
+	externalMetricsPath = "/apis/external.metrics.k8s.io/v1beta1"
+)
+
+type metricValueList struct {
+	Items []struct {
+		Value resource.Quantity `json:"value"`
+	} `json:"items"`
+}
+
+func getMetricValues(path, selector string) ([]float64, error) {
+	req := kubeClient.Discovery().RESTClient().Get().AbsPath(path)
+	if selector != "" {
+		req = req.Param("labelSelector", selector)
+	}
+	b, err := req.DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	list := metricValueList{}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	ret := make([]float64, 0, len(list.Items))
+	for _, i := range list.Items {
+		ret = append(ret, float64(i.Value.MilliValue())/1000)
+	}
+	return ret, nil
+}
+
+func sum(v []float64) float64 {
+	s := 0.0
+	for _, e := range v {
+		s += e
+	}
+	return s
+}
+
+// adapterValue returns the value the HPA controller would compute from the
+// metrics API for the metric, in the same form as statusMetrics reports it.
+func adapterValue(a as_v2.HorizontalPodAutoscaler, metric as_v2.MetricSpec) (float64, error) {
+	ns := a.ObjectMeta.Namespace
+	switch metric.Type {
+	case as_v2.ObjectMetricSourceType:
+		gv, err := schema.ParseGroupVersion(metric.Object.Target.APIVersion)
+		if err != nil {
+			return 0, err
+		}
+		r, _ := meta.UnsafeGuessKindToResource(gv.WithKind(metric.Object.Target.Kind))
+		v, err := getMetricValues(fmt.Sprintf("%s/namespaces/%s/%s/%s/%s", customMetricsPath, ns, r.Resource, metric.Object.Target.Name, metric.Object.MetricName), "")
+		if err != nil {
+			return 0, err
+		}
+		return sum(v), nil
+	case as_v2.PodsMetricSourceType:
+		t, err := cachedScaleTarget(a)
+		if err != nil {
+			return 0, err
+		}
+		v, err := getMetricValues(fmt.Sprintf("%s/namespaces/%s/pods/*/%s", customMetricsPath, ns, metric.Pods.MetricName), t.Selector)
+		if err != nil {
+			return 0, err
+		}
+		if len(v) == 0 {
+			return 0, fmt.Errorf("no pods metric `%s` returned", metric.Pods.MetricName)
+		}
+		return sum(v) / float64(len(v)), nil
+	case as_v2.ExternalMetricSourceType:
+		selector := ""
+		if metric.External.MetricSelector != nil {
+			sel, err := meta_v1.LabelSelectorAsSelector(metric.External.MetricSelector)
+			if err != nil {
+				return 0, err
+			}
+			selector = sel.String()
+		}
+		v, err := getMetricValues(fmt.Sprintf("%s/namespaces/%s/%s", externalMetricsPath, ns, metric.External.MetricName), selector)
+		if err != nil {
+			return 0, err
+		}
+		if metric.External.TargetAverageValue != nil {
+			if a.Status.CurrentReplicas == 0 {
+				return 0, fmt.Errorf("no current replicas")
+			}
+			return sum(v) / float64(a.Status.CurrentReplicas), nil
+		}
+		return sum(v), nil
+	}
+	return 0, fmt.Errorf("metric type `%s` is not served by the custom or external metrics API", metric.Type)
+}
+
+func crossCheckHpaMetrics(a as_v2.HorizontalPodAutoscaler, baseLabel prometheus.Labels) {
+	for _, metric := range a.Spec.Metrics {
+		if metric.Type == as_v2.ResourceMetricSourceType {
+			continue
+		}
+		m, ok := collector.SpecMetric(metric)
+		if !ok {
+			continue
+		}
+		v, err := adapterValue(a, metric)
+		if err != nil {
+			log.Debugf("cross check %s metric `%s` of %s: %v", metric.Type, m.MetricName, hpaKey(a), err)
+			continue
+		}
+		_, l := parseCommonMetrics(m)
+		hpaAdapterMetricsValue.With(mergeLabels(baseLabel, l)).Set(v)
+	}
+}
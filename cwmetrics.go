@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var cwMetricNamespace = flag.String("cwMetricNamespace", "", "CloudWatch namespace to publish HPA metrics to with PutMetricData. Nothing is published when empty.")
+var cwMetricNames = flag.String("cwMetricNames", defaultCWMetricNames, "Comma separated names of the metrics published to CloudWatch.")
+var cwMetricDimensions = flag.String("cwMetricDimensions", defaultCWMetricDimensions, "Comma separated labels published as CloudWatch dimensions. Labels a metric does not have, or has empty, are left out.")
+
+// cwMetricBatchSize is the limit of metric data per PutMetricData request.
+const cwMetricBatchSize = 1000
+
+type cwMetricsSink struct {
+	client   *http.Client
+	endpoint string
+	region   string
+	creds    *credentials.Credentials
+}
+
+// newCWMetricsSink calls the query API of CloudWatch directly, as the
+// vendored SDK has only the CloudWatch Logs client.
+func newCWMetricsSink() (*cwMetricsSink, error) {
+	sess, err := newAWSSession()
+	if err != nil {
+		return nil, err
+	}
+	region := aws.StringValue(sess.Config.Region)
+	endpoint := aws.StringValue(awsServiceConfig(sess, "monitoring").Endpoint)
+	if endpoint == "" {
+		e, err := endpoints.DefaultResolver().EndpointFor("monitoring", region)
+		if err != nil {
+			return nil, err
+		}
+		endpoint = e.URL
+	}
+	return &cwMetricsSink{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		endpoint: endpoint,
+		region:   region,
+		creds:    sess.Config.Credentials,
+	}, nil
+}
+
+func (s *cwMetricsSink) Name() string {
+	return "cloudwatch"
+}
+
+func (s *cwMetricsSink) putMetricData(form url.Values) error {
+	body := form.Encode()
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if _, err := v4.NewSigner(s.creds).Sign(req, strings.NewReader(body), "monitoring", s.region, time.Now()); err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("put metric data: %s: %s", res.Status, b)
+	}
+	return nil
+}
+
+func (s *cwMetricsSink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	samples, err := hpaSamples()
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, n := range splitList(*cwMetricNames) {
+		names[n] = true
+	}
+	dims := splitList(*cwMetricDimensions)
+	form := url.Values{}
+	n := 0
+	flush := func() error {
+		if n == 0 {
+			return nil
+		}
+		form.Set("Action", "PutMetricData")
+		form.Set("Version", "2010-08-01")
+		form.Set("Namespace", *cwMetricNamespace)
+		err := s.putMetricData(form)
+		form, n = url.Values{}, 0
+		return err
+	}
+	for _, m := range samples {
+		if !names[m.Name] {
+			continue
+		}
+		n++
+		p := "MetricData.member." + strconv.Itoa(n) + "."
+		form.Set(p+"MetricName", m.Name)
+		form.Set(p+"Value", strconv.FormatFloat(m.Value, 'g', -1, 64))
+		form.Set(p+"Timestamp", now.UTC().Format(time.RFC3339))
+		d := 0
+		for _, l := range dims {
+			if m.Labels[l] == "" {
+				continue
+			}
+			d++
+			dp := p + "Dimensions.member." + strconv.Itoa(d) + "."
+			form.Set(dp+"Name", l)
+			form.Set(dp+"Value", m.Labels[l])
+		}
+		if n == cwMetricBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
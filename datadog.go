@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var datadogAPIKey = newSecret("datadogAPIKey", "Datadog API key to submit the HPA metrics with as Datadog gauges. Nothing is submitted when empty.")
+var datadogSite = flag.String("datadogSite", defaultDatadogSite, "Datadog site to submit metrics to, e.g. datadoghq.eu or us5.datadoghq.com.")
+var datadogMetricPrefix = flag.String("datadogMetricPrefix", defaultDatadogMetricPrefix, "Prefix of the Datadog metric names.")
+var datadogTags = flag.String("datadogTags", "", "Comma separated tags added to every submitted metric, e.g. env:prod,cluster:main.")
+
+// datadogBatchSize keeps the payloads below the 3.2MB limit of the API.
+const datadogBatchSize = 1000
+
+type datadogSeries struct {
+	Metric string       `json:"metric"`
+	Type   string       `json:"type"`
+	Points [][2]float64 `json:"points"`
+	Tags   []string     `json:"tags"`
+}
+
+type datadogSink struct {
+	client *http.Client
+}
+
+func newDatadogSink() *datadogSink {
+	return &datadogSink{client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *datadogSink) Name() string {
+	return "datadog"
+}
+
+func datadogTagList(labels map[string]string) []string {
+	ret := splitList(*datadogTags)
+	for k, v := range labels {
+		if v == "" {
+			continue
+		}
+		ret = append(ret, k+":"+v)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (s *datadogSink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	samples, err := hpaSamples()
+	if err != nil {
+		return err
+	}
+	series := []datadogSeries{}
+	for _, m := range samples {
+		series = append(series, datadogSeries{
+			Metric: *datadogMetricPrefix + m.Name,
+			Type:   "gauge",
+			Points: [][2]float64{{float64(now.Unix()), m.Value}},
+			Tags:   datadogTagList(m.Labels),
+		})
+	}
+	url := "https://api." + strings.TrimPrefix(*datadogSite, "api.") + "/api/v1/series"
+	header := http.Header{"Dd-Api-Key": {datadogAPIKey.Get()}}
+	for len(series) > 0 {
+		n := len(series)
+		if n > datadogBatchSize {
+			n = datadogBatchSize
+		}
+		if err := postJSON(s.client, url, header, map[string]interface{}{"series": series[:n]}); err != nil {
+			return err
+		}
+		series = series[n:]
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+var debugEnvVars = []string{
+	"KUBECONFIG",
+	"KUBERNETES_SERVICE_HOST",
+	"KUBERNETES_SERVICE_PORT",
+	"AWS_REGION",
+	"AWS_DEFAULT_REGION",
+	"AWS_PROFILE",
+	"AWS_SDK_LOAD_CONFIG",
+	"AWS_CONFIG_FILE",
+	"AWS_SHARED_CREDENTIALS_FILE",
+	"AWS_ROLE_ARN",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"HTTP_PROXY",
+	"HTTPS_PROXY",
+	"NO_PROXY",
+}
+
+var secretEnvVars = map[string]bool{
+	"AWS_ACCESS_KEY_ID":     true,
+	"AWS_SECRET_ACCESS_KEY": true,
+	"AWS_SESSION_TOKEN":     true,
+}
+
+// urlEnvVars are URLs that may hold credentials as user info.
+var urlEnvVars = map[string]bool{
+	"HTTP_PROXY":  true,
+	"HTTPS_PROXY": true,
+}
+
+// redactURLUser redacts the user info of the URL s. Proxy URLs without a
+// scheme, like user:password@proxy:3128, are used as http URLs, so the user
+// info is looked for without parsing s as a URL.
+func redactURLUser(s string) string {
+	scheme, rest := "", s
+	if i := strings.Index(s, "://"); i >= 0 {
+		scheme, rest = s[:i+3], s[i+3:]
+	}
+	host := rest
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host = rest[:i]
+	}
+	i := strings.LastIndex(host, "@")
+	if i < 0 {
+		return s
+	}
+	return scheme + redacted + rest[i:]
+}
+
+type flagConfig struct {
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Source  string `json:"source"`
+}
+
+type effectiveConfig struct {
+	ConfigFile string                `json:"configFile"`
+	Flags      map[string]flagConfig `json:"flags"`
+	Env        map[string]string     `json:"env"`
+}
+
+func currentConfig() effectiveConfig {
+	c := effectiveConfig{
+		ConfigFile: *configFile,
+		Flags:      map[string]flagConfig{},
+		Env:        map[string]string{},
+	}
+	applied := currentAppliedConfig()
+	flag.VisitAll(func(f *flag.Flag) {
+		fc := flagConfig{
+			Value:   f.Value.String(),
+			Default: f.DefValue,
+			Source:  "default",
+		}
+		if v, ok := applied.values[f.Name]; ok {
+			// changed by reloads
+			fc.Value = v
+		}
+		if commandLineFlags[f.Name] {
+			fc.Source = "command line"
+		} else if applied.fromFile[f.Name] {
+			fc.Source = "config file"
+		}
+		if secretFlags[f.Name] && fc.Value != "" {
+			fc.Value = redacted
+		}
+		c.Flags[f.Name] = fc
+	})
+	for _, k := range debugEnvVars {
+		v, ok := os.LookupEnv(k)
+		if !ok {
+			continue
+		}
+		if secretEnvVars[k] {
+			v = redacted
+		} else if urlEnvVars[k] {
+			v = redactURLUser(v)
+		}
+		c.Env[k] = v
+	}
+	return c
+}
+
+func debugConfigHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(currentConfig())
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestRedactURLUser(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"http://proxy:3128", "http://proxy:3128"},
+		{"http://user:pw@proxy:3128", "http://<redacted>@proxy:3128"},
+		{"https://user@proxy:3128/path?q=a@b", "https://<redacted>@proxy:3128/path?q=a@b"},
+		{"user:p@ss@proxy:3128", "<redacted>@proxy:3128"},
+		{"proxy:3128", "proxy:3128"},
+		{"", ""},
+	} {
+		if got := redactURLUser(c.in); got != c.want {
+			t.Errorf("redactURLUser(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
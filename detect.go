@@ -0,0 +1,402 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+var flapWindow = flag.Duration("flapWindow", defaultFlapWindow, "Window in which direction changes of desired replicas are counted for flapping detection.")
+var flapThreshold = flag.Int("flapThreshold", defaultFlapThreshold, "An HPA is flapping when desired replicas changed direction more than this many times within flapWindow.")
+var idleFor = flag.Duration("idleFor", defaultIdleFor, "Duration an HPA must stay at min replicas with all metrics below idleRatio of their target to be reported as idle.")
+var idleRatio = flag.Float64("idleRatio", defaultIdleRatio, "Ratio of current to target metric value under which an HPA at min replicas is considered idle.")
+var replicasConflictFor = flag.Duration("replicasConflictFor", defaultReplicasConflictFor, "Duration the scale target's spec.replicas must differ from the HPA's desired replicas to be reported as a conflict. Needs -checkTarget.")
+var stuckAtMaxFor = flag.Duration("stuckAtMaxFor", defaultStuckAtMaxFor, "Duration an HPA must be at max replicas with a metric above its target to be reported as stuck.")
+
+// hpaHistory is what the detectors remember about an HPA between
+// collections. It is only accessed while holding collectMu.
+type hpaHistory struct {
+	labels prometheus.Labels
+
+	lastDesired      int32
+	lastDirection    int
+	directionChanges []time.Time
+
+	saturatedSince time.Time
+	idleSince      time.Time
+
+	replicasMismatchSince time.Time
+
+	metricFetchFailed map[string]bool
+
+	demand []demandSample
+
+	lastObserved time.Time
+	wasAtMax     bool
+
+	convergeTo    int32
+	convergeUp    bool
+	convergeSince time.Time
+}
+
+var histories = map[string]*hpaHistory{}
+
+var historyCounters = []*prometheus.CounterVec{
+	hpaDirectionChanges,
+	hpaTimeAtMax,
+}
+
+func hpaKey(a as_v2.HorizontalPodAutoscaler) string {
+	return a.ObjectMeta.Namespace + "/" + a.ObjectMeta.Name
+}
+
+func labelsEqual(l1, l2 prometheus.Labels) bool {
+	if len(l1) != len(l2) {
+		return false
+	}
+	for k, v := range l1 {
+		if l2[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func deleteHistoryCounters(labels prometheus.Labels) {
+	for _, c := range historyCounters {
+		c.Delete(labels)
+	}
+	for _, d := range []string{"up", "down"} {
+		hpaScaleConvergence.Delete(mergeLabels(labels, prometheus.Labels{"direction": d}))
+	}
+}
+
+func historyOf(a as_v2.HorizontalPodAutoscaler, labels prometheus.Labels) *hpaHistory {
+	h, ok := histories[hpaKey(a)]
+	if !ok {
+		h = &hpaHistory{
+			lastDesired: a.Status.DesiredReplicas,
+			demand:      storedDemand(hpaKey(a), time.Now().Add(-*recommendWindow)),
+		}
+		histories[hpaKey(a)] = h
+	} else if !labelsEqual(h.labels, labels) {
+		deleteHistoryCounters(h.labels)
+	}
+	h.labels = labels
+	return h
+}
+
+func pruneHistories(seen map[string]bool) {
+	for k, h := range histories {
+		if !seen[k] {
+			deleteHistoryCounters(h.labels)
+			delete(histories, k)
+		}
+	}
+}
+
+func observeHpa(a as_v2.HorizontalPodAutoscaler, labels prometheus.Labels, now time.Time) {
+	h := historyOf(a, labels)
+	observeConvergence(h, a, now)
+	detectFlapping(h, a, now)
+	detectStuckAtMax(h, a, now)
+	detectIdle(h, a, now)
+	detectDisabled(h, a)
+	explainScalingLimited(h, a)
+	detectMetricFetchFailures(h, a)
+	recommendReplicas(h, a, now)
+	accumulateTimeAtMax(h, a, now)
+	if *checkTarget {
+		t, err := cachedScaleTarget(a)
+		if err != nil {
+			if _, ok := err.(unsupportedTargetError); !ok {
+				log.Warnf("get scale target of %s: %v", hpaKey(a), err)
+			}
+			return
+		}
+		detectReplicasConflict(h, a, t, now)
+		hpaTargetInfo.With(mergeLabels(h.labels, prometheus.Labels{"selector": t.Selector})).Set(1)
+		hpaTargetReplicas.With(h.labels).Set(float64(t.StatusReplicas))
+		if t.Subresource {
+			return
+		}
+		hpaTargetPodCPURequest.With(h.labels).Set(t.PodResources.CPURequest)
+		hpaTargetPodCPULimit.With(h.labels).Set(t.PodResources.CPULimit)
+		hpaTargetPodMemoryRequest.With(h.labels).Set(t.PodResources.MemoryRequest)
+		hpaTargetPodMemoryLimit.With(h.labels).Set(t.PodResources.MemoryLimit)
+		hpaTargetReadyPods.With(h.labels).Set(float64(t.ReadyReplicas))
+		unready := t.StatusReplicas - t.ReadyReplicas
+		if unready < 0 {
+			unready = 0
+		}
+		hpaTargetUnreadyPods.With(h.labels).Set(float64(unready))
+	}
+}
+
+// metricRatios returns current/target of each metric the HPA reports a
+// current value for, keyed by metricKey.
+func metricRatios(a as_v2.HorizontalPodAutoscaler) map[string]float64 {
+	current := map[string]float64{}
+	for _, m := range statusMetrics(a) {
+		current[metricKey(m)] = m.Value
+	}
+	ret := map[string]float64{}
+	for _, t := range specMetrics(a) {
+		c, ok := current[metricKey(t)]
+		if !ok || t.Value == 0 {
+			continue
+		}
+		ret[metricKey(t)] = c / t.Value
+	}
+	return ret
+}
+
+func aboveTarget(a as_v2.HorizontalPodAutoscaler) bool {
+	for _, r := range metricRatios(a) {
+		if r > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func detectStuckAtMax(h *hpaHistory, a as_v2.HorizontalPodAutoscaler, now time.Time) {
+	if a.Status.CurrentReplicas < a.Spec.MaxReplicas || !aboveTarget(a) {
+		h.saturatedSince = time.Time{}
+		hpaStuckAtMax.With(h.labels).Set(0)
+		return
+	}
+	if h.saturatedSince.IsZero() {
+		h.saturatedSince = now
+	}
+	if now.Sub(h.saturatedSince) >= *stuckAtMaxFor {
+		hpaStuckAtMax.With(h.labels).Set(1)
+	} else {
+		hpaStuckAtMax.With(h.labels).Set(0)
+	}
+}
+
+// observeConvergence measures how long current replicas take to reach
+// desired replicas after desired replicas changed.
+func observeConvergence(h *hpaHistory, a as_v2.HorizontalPodAutoscaler, now time.Time) {
+	desired := a.Status.DesiredReplicas
+	if desired != h.lastDesired {
+		h.convergeTo = desired
+		h.convergeUp = desired > h.lastDesired
+		h.convergeSince = now
+	}
+	if h.convergeSince.IsZero() || a.Status.CurrentReplicas != h.convergeTo {
+		return
+	}
+	direction := "up"
+	if !h.convergeUp {
+		direction = "down"
+	}
+	hpaScaleConvergence.With(mergeLabels(h.labels, prometheus.Labels{"direction": direction})).Observe(now.Sub(h.convergeSince).Seconds())
+	h.convergeSince = time.Time{}
+}
+
+func detectFlapping(h *hpaHistory, a as_v2.HorizontalPodAutoscaler, now time.Time) {
+	desired := a.Status.DesiredReplicas
+	if desired != h.lastDesired {
+		direction := 1
+		if desired < h.lastDesired {
+			direction = -1
+		}
+		if h.lastDirection != 0 && direction != h.lastDirection {
+			h.directionChanges = append(h.directionChanges, now)
+			hpaDirectionChanges.With(h.labels).Inc()
+		}
+		h.lastDirection = direction
+		h.lastDesired = desired
+	}
+
+	cut := now.Add(-*flapWindow)
+	i := 0
+	for i < len(h.directionChanges) && h.directionChanges[i].Before(cut) {
+		i++
+	}
+	h.directionChanges = h.directionChanges[i:]
+
+	if len(h.directionChanges) > *flapThreshold {
+		hpaFlapping.With(h.labels).Set(1)
+	} else {
+		hpaFlapping.With(h.labels).Set(0)
+	}
+}
+
+func minReplicas(a as_v2.HorizontalPodAutoscaler) int32 {
+	if a.Spec.MinReplicas == nil {
+		return 1
+	}
+	return *a.Spec.MinReplicas
+}
+
+func belowIdleRatio(a as_v2.HorizontalPodAutoscaler) bool {
+	ratios := metricRatios(a)
+	if len(ratios) == 0 {
+		return false
+	}
+	for _, r := range ratios {
+		if r >= *idleRatio {
+			return false
+		}
+	}
+	return true
+}
+
+// detectDisabled reports HPAs that are pinned to a fixed replica count or
+// that the controller has turned off because the target is scaled to zero.
+func detectDisabled(h *hpaHistory, a as_v2.HorizontalPodAutoscaler) {
+	if minReplicas(a) == a.Spec.MaxReplicas {
+		hpaEffectivelyDisabled.With(mergeLabels(h.labels, prometheus.Labels{"reason": "min_equals_max"})).Set(1)
+	}
+	for _, c := range a.Status.Conditions {
+		if c.Type == as_v2.ScalingActive && c.Status == core_v1.ConditionFalse && c.Reason == "ScalingDisabled" {
+			hpaEffectivelyDisabled.With(mergeLabels(h.labels, prometheus.Labels{"reason": "target_scaled_to_zero"})).Set(1)
+		}
+	}
+}
+
+// stabilizationReasons are AbleToScale reasons set while the controller
+// holds replicas above what the metrics ask for.
+var stabilizationReasons = map[string]bool{
+	"ScaleDownStabilized": true,
+	"BackoffDownscale":    true,
+	"BackoffBoth":         true,
+}
+
+// explainScalingLimited classifies why the HPA is not at the replicas its
+// metrics ask for. ScalingLimited's reason alone does not tell a replica
+// bound from a rate limit, and stabilization is only visible in AbleToScale.
+func explainScalingLimited(h *hpaHistory, a as_v2.HorizontalPodAutoscaler) {
+	var limited, able *as_v2.HorizontalPodAutoscalerCondition
+	for i, c := range a.Status.Conditions {
+		switch c.Type {
+		case as_v2.ScalingLimited:
+			limited = &a.Status.Conditions[i]
+		case as_v2.AbleToScale:
+			able = &a.Status.Conditions[i]
+		}
+	}
+	demand := math.Ceil(demandReplicas(a))
+	cause := ""
+	switch {
+	case able != nil && stabilizationReasons[able.Reason] && demand < float64(a.Status.CurrentReplicas):
+		cause = "scale_down_stabilization"
+	case limited == nil || limited.Status != core_v1.ConditionTrue:
+	case limited.Reason == "ScaleUpLimit" || limited.Reason == "ScaleDownLimit":
+		cause = "rate_limit"
+	case a.Status.DesiredReplicas >= a.Spec.MaxReplicas:
+		cause = "max_replicas"
+	case a.Status.DesiredReplicas <= minReplicas(a):
+		cause = "min_replicas"
+	default:
+		cause = "unknown"
+	}
+	if cause != "" {
+		hpaScalingLimitedCause.With(mergeLabels(h.labels, prometheus.Labels{"cause": cause})).Set(1)
+	}
+}
+
+// accumulateTimeAtMax counts the time between two observations at max
+// replicas.
+func accumulateTimeAtMax(h *hpaHistory, a as_v2.HorizontalPodAutoscaler, now time.Time) {
+	atMax := a.Status.CurrentReplicas >= a.Spec.MaxReplicas
+	if h.wasAtMax && atMax && !h.lastObserved.IsZero() {
+		hpaTimeAtMax.With(h.labels).Add(now.Sub(h.lastObserved).Seconds())
+	}
+	h.wasAtMax = atMax
+	h.lastObserved = now
+}
+
+func detectIdle(h *hpaHistory, a as_v2.HorizontalPodAutoscaler, now time.Time) {
+	if a.Status.CurrentReplicas > minReplicas(a) || !belowIdleRatio(a) {
+		h.idleSince = time.Time{}
+		hpaIdle.With(h.labels).Set(0)
+		return
+	}
+	if h.idleSince.IsZero() {
+		h.idleSince = now
+	}
+	if now.Sub(h.idleSince) >= *idleFor {
+		hpaIdle.With(h.labels).Set(1)
+	} else {
+		hpaIdle.With(h.labels).Set(0)
+	}
+}
+
+// detectReplicasConflict reports HPAs whose target replicas keep being set
+// to something other than the desired count, e.g. by a GitOps controller
+// syncing replicas committed to the manifest.
+func detectReplicasConflict(h *hpaHistory, a as_v2.HorizontalPodAutoscaler, t *scaleTarget, now time.Time) {
+	if t.SpecReplicas == a.Status.DesiredReplicas {
+		h.replicasMismatchSince = time.Time{}
+		hpaTargetReplicasConflict.With(h.labels).Set(0)
+		return
+	}
+	if h.replicasMismatchSince.IsZero() {
+		h.replicasMismatchSince = now
+	}
+	if now.Sub(h.replicasMismatchSince) >= *replicasConflictFor {
+		hpaTargetReplicasConflict.With(h.labels).Set(1)
+	} else {
+		hpaTargetReplicasConflict.With(h.labels).Set(0)
+	}
+}
+
+func detectDuplicateTargets(hpa []as_v2.HorizontalPodAutoscaler) {
+	byTarget := map[string][]as_v2.HorizontalPodAutoscaler{}
+	for _, a := range hpa {
+		k := a.ObjectMeta.Namespace + "/" + a.Spec.ScaleTargetRef.Kind + "/" + a.Spec.ScaleTargetRef.Name
+		byTarget[k] = append(byTarget[k], a)
+	}
+	for _, same := range byTarget {
+		if len(same) < 2 {
+			continue
+		}
+		for _, a := range same {
+			for _, o := range same {
+				if o.ObjectMeta.Name == a.ObjectMeta.Name {
+					continue
+				}
+				hpaDuplicateTarget.With(mergeLabels(hpaBaseLabels(a), prometheus.Labels{"other_hpa": o.ObjectMeta.Name})).Set(1)
+			}
+		}
+	}
+}
+
+// metricFetchFailureReasons maps ScalingActive reasons set by the HPA
+// controller when it cannot get a metric to the metric source.
+var metricFetchFailureReasons = map[string]string{
+	"FailedGetResourceMetric": "Resource",
+	"FailedGetPodsMetric":     "Pods",
+	"FailedGetObjectMetric":   "Object",
+	"FailedGetExternalMetric": "External",
+}
+
+func detectMetricFetchFailures(h *hpaHistory, a as_v2.HorizontalPodAutoscaler) {
+	failed := map[string]bool{}
+	for _, c := range a.Status.Conditions {
+		if source, ok := metricFetchFailureReasons[c.Reason]; ok && c.Status == core_v1.ConditionFalse {
+			failed[source] = true
+		}
+	}
+	for _, source := range metricFetchFailureReasons {
+		l := mergeLabels(h.labels, prometheus.Labels{"metric_source": source})
+		if failed[source] {
+			hpaMetricFetchFailed.With(l).Set(1)
+			if !h.metricFetchFailed[source] {
+				hpaMetricFetchFailures.With(prometheus.Labels{"metric_source": source}).Inc()
+			}
+		} else {
+			hpaMetricFetchFailed.With(l).Set(0)
+		}
+	}
+	h.metricFetchFailed = failed
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// discordConfig posts findings as embeds to Discord webhooks, linking each
+// to Grafana with the templated grafanaURL.
+type discordConfig struct {
+	URL        string            `yaml:"url"`
+	Namespaces map[string]string `yaml:"namespaces"`
+	Username   string            `yaml:"username"`
+	GrafanaURL string            `yaml:"grafanaURL"`
+	Timeout    time.Duration     `yaml:"timeout"`
+}
+
+// a Discord message has at most 10 embeds
+const discordMaxEmbeds = 10
+
+type discordReceiver struct {
+	config   *discordConfig
+	client   *http.Client
+	grafana  *template.Template
+	template *messageTemplate
+}
+
+func newDiscordReceiver(c *discordConfig, t *messageTemplate) (*discordReceiver, error) {
+	if c.URL == "" && len(c.Namespaces) == 0 {
+		return nil, fmt.Errorf("discord has neither url nor namespaces")
+	}
+	if c.Username == "" {
+		c.Username = "hpa-exporter"
+	}
+	r := &discordReceiver{config: c, template: t}
+	var err error
+	if r.grafana, err = parseNotifyTemplate("grafanaURL", c.GrafanaURL); err != nil {
+		return nil, err
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	r.client = &http.Client{Timeout: timeout}
+	return r, nil
+}
+
+func (r *discordReceiver) embed(f finding) (map[string]interface{}, error) {
+	title, body, facts, err := r.template.message(f)
+	if err != nil {
+		return nil, err
+	}
+	if r.template.title == nil {
+		title += " " + hpaKey(f.HPA)
+	}
+	color := 0xf2a900
+	switch {
+	case f.Status == findingResolved:
+		color = 0x2eb886
+	case f.Severity == "critical":
+		color = 0xd0021b
+	}
+	fields := []map[string]interface{}{}
+	for _, kv := range facts {
+		fields = append(fields, map[string]interface{}{"name": kv[0], "value": kv[1], "inline": true})
+	}
+	e := map[string]interface{}{
+		"title":       title,
+		"description": body,
+		"color":       color,
+		"fields":      fields,
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+	}
+	link, err := executeTemplate(r.grafana, newNotifyData([]finding{f}))
+	if err != nil {
+		return nil, err
+	}
+	if link != "" {
+		e["url"] = link
+	}
+	return e, nil
+}
+
+func (r *discordReceiver) Notify(fs []finding) error {
+	embeds := map[string][]map[string]interface{}{}
+	urls := []string{}
+	for _, f := range fs {
+		url := namespaceURL(r.config.URL, r.config.Namespaces, f.Namespace)
+		if url == "" {
+			continue
+		}
+		e, err := r.embed(f)
+		if err != nil {
+			return err
+		}
+		if _, ok := embeds[url]; !ok {
+			urls = append(urls, url)
+		}
+		embeds[url] = append(embeds[url], e)
+	}
+	for _, url := range urls {
+		es := embeds[url]
+		for len(es) > 0 {
+			n := len(es)
+			if n > discordMaxEmbeds {
+				n = discordMaxEmbeds
+			}
+			if err := postJSON(r.client, url, nil, map[string]interface{}{"username": r.config.Username, "embeds": es[:n]}); err != nil {
+				return err
+			}
+			es = es[n:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	authz_v1 "k8s.io/api/authorization/v1"
+)
+
+var dryRun = flag.Bool("dryRun", false, "Validate configuration, Kubernetes RBAC and logging backend credentials, then exit without starting the server.")
+
+type check struct {
+	Name string
+	Run  func() error
+}
+
+func runDryRun() bool {
+	checks := []check{
+		{"config file", func() error { return loadConfigFile(*configFile) }},
+		{"flags", validateFlags},
+		{"tenants file", func() error { return loadTenants(*tenantsFile) }},
+		{"notify config", func() error { return loadNotifyConfig(*notifyConfigFile) }},
+		{"logging schedule", func() error { return loadLoggingSchedule(*loggingScheduleFile) }},
+		{"kubernetes client", func() (err error) {
+			kubeClient, err = newKubeClient()
+			return
+		}},
+		{"list horizontalpodautoscalers", checkHpaListAccess},
+	}
+	if *conditionLogging && *loggingTo == "cwlogs" {
+		checks = append(checks,
+			check{"cloudwatch logs session", func() error {
+				cw, err := newCWSession()
+				setCWClient(cw)
+				return err
+			}},
+			check{"describe log groups", checkDescribeLogGroups},
+			check{"describe log streams", checkDescribeLogStreams},
+			check{"put log events", checkAWSIdentity},
+		)
+	}
+	if (*conditionLogging || *auditLog) && *loggingTo == "file" {
+		checks = append(checks, check{"write log file", checkLogFile})
+	}
+	if *conditionLogging && *loggingTo == "otlp" {
+		checks = append(checks, check{"export otlp logs", checkOTLPExport})
+	}
+
+	ok := true
+	for _, c := range checks {
+		if err := c.Run(); err != nil {
+			fmt.Printf("FAIL %s: %v\n", c.Name, err)
+			ok = false
+			continue
+		}
+		fmt.Printf("OK   %s\n", c.Name)
+	}
+	return ok
+}
+
+// checkHpaListAccess reviews the access to each namespace HPAs are listed
+// in, all namespaces when none is set.
+func checkHpaListAccess() error {
+	if kubeClient == nil {
+		return fmt.Errorf("no kubernetes client")
+	}
+	denied := []string{}
+	for _, ns := range listedNamespaces() {
+		review := &authz_v1.SelfSubjectAccessReview{
+			Spec: authz_v1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authz_v1.ResourceAttributes{
+					Namespace: ns,
+					Verb:      "list",
+					Group:     "autoscaling",
+					Resource:  "horizontalpodautoscalers",
+				},
+			},
+		}
+		r, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+		if err != nil {
+			return err
+		}
+		if !r.Status.Allowed {
+			if ns == "" {
+				ns = "all namespaces"
+			}
+			denied = append(denied, fmt.Sprintf("%s (%s)", ns, r.Status.Reason))
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("not allowed in %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+func checkDescribeLogGroups() error {
+	if cwClient() == nil {
+		return fmt.Errorf("no cloudwatch logs session")
+	}
+	r, err := cwClient().DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: cwLogGroup,
+	})
+	if err != nil {
+		return err
+	}
+	if len(r.LogGroups) == 0 {
+		fmt.Printf("     log group `%s` does not exist and will be created on start\n", *cwLogGroup)
+	}
+	return nil
+}
+
+func checkDescribeLogStreams() error {
+	if cwClient() == nil {
+		return fmt.Errorf("no cloudwatch logs session")
+	}
+	_, err := cwClient().DescribeLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName:        cwLogGroup,
+		LogStreamNamePrefix: cwLogStream,
+	})
+	if ae, ok := err.(awserr.Error); ok && ae.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException {
+		fmt.Printf("     log group `%s` not found, skipping log stream check\n", *cwLogGroup)
+		return nil
+	}
+	return err
+}
+
+// checkLogFile opens logFile for appending, or creates and removes a file
+// next to it when it does not exist yet, so the dry run leaves no file.
+func checkLogFile() error {
+	f, err := os.OpenFile(*logFile, os.O_WRONLY|os.O_APPEND, 0)
+	if os.IsNotExist(err) {
+		f, err = ioutil.TempFile(filepath.Dir(*logFile), ".hpa-exporter-dryrun")
+		if err == nil {
+			defer os.Remove(f.Name())
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// checkOTLPExport exports no logs, which collectors accept, to check the
+// endpoint, the TLS settings and the headers.
+func checkOTLPExport() error {
+	client, err := otlpHTTPClient()
+	if err != nil {
+		return err
+	}
+	return postJSON(client, *otlpLogsEndpoint, otlpHeader(), otlpLogsRequest{ResourceLogs: []otlpResourceLogs{}})
+}
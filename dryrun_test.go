@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	authz_v1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+	authorization_v1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// reviewClient allows listing in the namespaces of allowed and records the
+// namespaces reviewed.
+type reviewClient struct {
+	kubernetes.Interface
+	authorization_v1.AuthorizationV1Interface
+	authorization_v1.SelfSubjectAccessReviewInterface
+
+	allowed  map[string]bool
+	reviewed []string
+}
+
+func (c *reviewClient) AuthorizationV1() authorization_v1.AuthorizationV1Interface {
+	return c
+}
+
+func (c *reviewClient) SelfSubjectAccessReviews() authorization_v1.SelfSubjectAccessReviewInterface {
+	return c
+}
+
+func (c *reviewClient) Create(r *authz_v1.SelfSubjectAccessReview) (*authz_v1.SelfSubjectAccessReview, error) {
+	ns := r.Spec.ResourceAttributes.Namespace
+	c.reviewed = append(c.reviewed, ns)
+	r.Status.Allowed = c.allowed[ns]
+	if !r.Status.Allowed {
+		r.Status.Reason = "no RBAC policy matched"
+	}
+	return r, nil
+}
+
+func TestCheckHpaListAccess(t *testing.T) {
+	defer func(c kubernetes.Interface, ns string) {
+		kubeClient = c
+		*namespaces = ns
+	}(kubeClient, *namespaces)
+
+	for _, c := range []struct {
+		namespaces string
+		allowed    map[string]bool
+		reviewed   string
+		denied     string
+	}{
+		{"", map[string]bool{"": true}, "", ""},
+		{"", map[string]bool{"prod": true}, "", "all namespaces"},
+		{"prod,dev", map[string]bool{"prod": true, "dev": true}, "prod,dev", ""},
+		{"prod,dev", map[string]bool{"prod": true}, "prod,dev", "dev (no RBAC policy matched)"},
+	} {
+		*namespaces = c.namespaces
+		rc := &reviewClient{allowed: c.allowed}
+		kubeClient = rc
+		err := checkHpaListAccess()
+		if got := strings.Join(rc.reviewed, ","); got != c.reviewed {
+			t.Errorf("%s: reviewed `%s`, want `%s`", c.namespaces, got, c.reviewed)
+		}
+		switch {
+		case c.denied == "" && err != nil:
+			t.Errorf("%s: %v", c.namespaces, err)
+		case c.denied != "" && (err == nil || !strings.Contains(err.Error(), c.denied)):
+			t.Errorf("%s: got %v, want denied in %s", c.namespaces, err, c.denied)
+		}
+	}
+}
+
+func TestCheckLogFile(t *testing.T) {
+	defer func(f string) { *logFile = f }(*logFile)
+	dir, err := ioutil.TempDir("", "dryrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	*logFile = filepath.Join(dir, "hpa.jsonl")
+	if err := checkLogFile(); err != nil {
+		t.Fatal(err)
+	}
+	if fs, _ := ioutil.ReadDir(dir); len(fs) != 0 {
+		t.Fatalf("got %d files, want the dry run to leave none", len(fs))
+	}
+	if err := ioutil.WriteFile(*logFile, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkLogFile(); err != nil {
+		t.Fatal(err)
+	}
+	*logFile = filepath.Join(dir, "missing", "hpa.jsonl")
+	if err := checkLogFile(); err == nil {
+		t.Fatal("want an error for a missing directory")
+	}
+}
+
+func TestCheckOTLPExport(t *testing.T) {
+	defer func(e, h string) { *otlpLogsEndpoint, *otlpHeaders.value = e, h }(*otlpLogsEndpoint, *otlpHeaders.value)
+	defer resetOTLPClient()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	*otlpLogsEndpoint = srv.URL + "/v1/logs"
+	*otlpHeaders.value = "authorization=Bearer token"
+	if err := checkOTLPExport(); err != nil {
+		t.Fatal(err)
+	}
+	*otlpHeaders.value = ""
+	if err := checkOTLPExport(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("got %v, want the rejected export", err)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// emailConfig mails findings over SMTP, either one mail per finding or, in
+// digest mode, all findings of digestInterval in one mail.
+type emailConfig struct {
+	Host           string        `yaml:"host"`
+	Port           int           `yaml:"port"`
+	TLS            string        `yaml:"tls"`
+	Username       string        `yaml:"username"`
+	Password       string        `yaml:"password"`
+	PasswordFile   string        `yaml:"passwordFile"`
+	From           string        `yaml:"from"`
+	To             []string      `yaml:"to"`
+	Subject        string        `yaml:"subject"`
+	Body           string        `yaml:"body"`
+	Digest         bool          `yaml:"digest"`
+	DigestInterval time.Duration `yaml:"digestInterval"`
+}
+
+const (
+	defaultEmailSubject       = `[hpa-exporter] {{ .Rule }} {{ .Namespace }}/{{ .Name }}`
+	defaultEmailDigestSubject = `[hpa-exporter] {{ len .Findings }} findings`
+	defaultEmailBody          = `{{ range .Findings }}[{{ .Status }}] {{ .Summary }}
+{{ range facts . }}  {{ index . 0 }}: {{ index . 1 }}
+{{ end }}
+{{ end }}`
+)
+
+type emailReceiver struct {
+	config  *emailConfig
+	subject *template.Template
+	body    *template.Template
+}
+
+// emailDigest buffers the findings it is notified of and mails them in one
+// mail when flushed after digestInterval.
+type emailDigest struct {
+	*emailReceiver
+
+	mu         sync.Mutex
+	pending    map[string]finding
+	order      []string
+	lastDigest time.Time
+}
+
+func newEmailReceiver(c *emailConfig) (receiver, error) {
+	if c.Host == "" || c.From == "" || len(c.To) == 0 {
+		return nil, fmt.Errorf("email needs host, from and to")
+	}
+	if c.TLS == "" {
+		c.TLS = "starttls"
+	}
+	if c.Port == 0 {
+		c.Port = 587
+		if c.TLS == "tls" {
+			c.Port = 465
+		}
+	}
+	switch c.TLS {
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("invalid tls `%s`, must be one of `starttls`, `tls` or `none`", c.TLS)
+	}
+	if c.Subject == "" {
+		c.Subject = defaultEmailSubject
+		if c.Digest {
+			c.Subject = defaultEmailDigestSubject
+		}
+	}
+	if c.Body == "" {
+		c.Body = defaultEmailBody
+	}
+	r := &emailReceiver{config: c}
+	var err error
+	if r.subject, err = parseNotifyTemplate("subject", c.Subject); err != nil {
+		return nil, err
+	}
+	if r.body, err = parseNotifyTemplate("body", c.Body); err != nil {
+		return nil, err
+	}
+	if c.Digest {
+		return &emailDigest{emailReceiver: r, pending: map[string]finding{}}, nil
+	}
+	return r, nil
+}
+
+func (r *emailReceiver) Notify(fs []finding) error {
+	for _, f := range fs {
+		if err := r.send([]finding{f}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Notify buffers the findings. A finding replaces the buffered one of the
+// same key, e.g. when it resolved in the meantime.
+func (d *emailDigest) Notify(fs []finding) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, f := range fs {
+		if _, ok := d.pending[f.Key()]; !ok {
+			d.order = append(d.order, f.Key())
+		}
+		d.pending[f.Key()] = f
+	}
+	return nil
+}
+
+// Flush mails the buffered findings when digestInterval passed since the
+// last digest, or anyway with force. They stay buffered when it fails.
+func (d *emailDigest) Flush(now time.Time, force bool) ([]finding, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.pending) == 0 || (!force && now.Sub(d.lastDigest) < d.config.DigestInterval) {
+		return nil, nil
+	}
+	digest := []finding{}
+	for _, k := range d.order {
+		digest = append(digest, d.pending[k])
+	}
+	if err := d.send(digest); err != nil {
+		return nil, err
+	}
+	d.pending, d.order, d.lastDigest = map[string]finding{}, nil, now
+	return digest, nil
+}
+
+// SendNow mails fs in a mail of their own, leaving the buffered findings.
+func (d *emailDigest) SendNow(fs []finding) error {
+	return d.send(fs)
+}
+
+func (r *emailReceiver) message(fs []finding) ([]byte, error) {
+	data := newNotifyData(fs)
+	subject, err := executeTemplate(r.subject, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := executeTemplate(r.body, data)
+	if err != nil {
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "From: %s\r\n", r.config.From)
+	fmt.Fprintf(b, "To: %s\r\n", strings.Join(r.config.To, ", "))
+	fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return b.Bytes(), nil
+}
+
+func (r *emailReceiver) send(fs []finding) error {
+	msg, err := r.message(fs)
+	if err != nil {
+		return err
+	}
+	c := r.config
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	tlsConfig := &tls.Config{ServerName: c.Host}
+	var conn net.Conn
+	if c.TLS == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, 30*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if c.TLS == "starttls" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.Username != "" {
+		password := c.Password
+		if c.PasswordFile != "" {
+			b, err := ioutil.ReadFile(c.PasswordFile)
+			if err != nil {
+				return err
+			}
+			password = strings.TrimSpace(string(b))
+		}
+		if err := client.Auth(smtp.PlainAuth("", c.Username, password, c.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	for _, to := range c.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/prometheus/common/log"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var hpaEvents = flag.Bool("hpaEvents", defaultHpaEvents, "Watch Kubernetes Events of HPAs and count them in hpa_events_total.")
+
+const hpaEventSelector = "involvedObject.kind=HorizontalPodAutoscaler"
+
+// eventCounts remembers the count of each Event so that repeated Events,
+// which the API server aggregates into one object, are counted by their
+// increase. Each watched namespace has its own.
+type eventCounts map[types.UID]int32
+
+func (c eventCounts) count(e *core_v1.Event) {
+	n := e.Count
+	if n == 0 {
+		n = 1
+	}
+	prev, ok := c[e.ObjectMeta.UID]
+	c[e.ObjectMeta.UID] = n
+	if ok && n <= prev {
+		return
+	}
+	hpaEventsTotal.With(map[string]string{
+		"hpa_namespace": e.InvolvedObject.Namespace,
+		"hpa_name":      e.InvolvedObject.Name,
+		"event_type":    e.Type,
+		"event_reason":  e.Reason,
+	}).Add(float64(n - prev))
+}
+
+// listHpaEvents records the current Events of the namespace without
+// counting them, and returns the resourceVersion to watch from.
+func listHpaEvents(ns string, counts eventCounts) (string, error) {
+	l, err := kubeClient.CoreV1().Events(ns).List(meta_v1.ListOptions{FieldSelector: hpaEventSelector})
+	if err != nil {
+		return "", err
+	}
+	for uid := range counts {
+		delete(counts, uid)
+	}
+	for _, e := range l.Items {
+		n := e.Count
+		if n == 0 {
+			n = 1
+		}
+		counts[e.ObjectMeta.UID] = n
+	}
+	return l.ListMeta.ResourceVersion, nil
+}
+
+func watchHpaEvents() {
+	for _, ns := range listedNamespaces() {
+		go watchNamespaceHpaEvents(ns)
+	}
+}
+
+func watchNamespaceHpaEvents(ns string) {
+	counts := eventCounts{}
+	rv := ""
+	for {
+		if rv == "" {
+			var err error
+			rv, err = listHpaEvents(ns, counts)
+			if err != nil {
+				log.Errorln(err)
+				time.Sleep(time.Duration(*metricsInterval) * time.Second)
+				continue
+			}
+		}
+		w, err := kubeClient.CoreV1().Events(ns).Watch(meta_v1.ListOptions{
+			FieldSelector:   hpaEventSelector,
+			ResourceVersion: rv,
+		})
+		if err != nil {
+			log.Errorln(err)
+			rv = ""
+			time.Sleep(time.Duration(*metricsInterval) * time.Second)
+			continue
+		}
+		for ev := range w.ResultChan() {
+			e, ok := ev.Object.(*core_v1.Event)
+			if !ok {
+				// the watch failed, e.g. resourceVersion too old
+				rv = ""
+				break
+			}
+			rv = e.ObjectMeta.ResourceVersion
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				counts.count(e)
+			case watch.Deleted:
+				delete(counts, e.ObjectMeta.UID)
+			}
+		}
+		w.Stop()
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var featureGatesFlag = flag.String("feature-gates", "", "Comma separated Name=true|false pairs enabling or disabling features, e.g. HPAInformers=true. Read on start. See the README for the features.")
+
+// featureGate turns a subsystem on or off. Alpha features are off unless
+// enabled, Beta features are on unless disabled.
+type featureGate struct {
+	Default bool
+	Stage   string
+}
+
+const (
+	// featureHPAInformers watches the HPAs of -namespaces instead of listing
+	// them on every collection.
+	featureHPAInformers = "HPAInformers"
+	// featureJSONAPI serves /api/v1 and /api/openapi.json.
+	featureJSONAPI = "JSONAPI"
+	// featureNotifiers notifies the receivers of -notifyConfig and serves
+	// /-/silences and /-/notify/test.
+	featureNotifiers = "Notifiers"
+)
+
+var featureGates = map[string]featureGate{
+	featureHPAInformers: {Default: false, Stage: "Alpha"},
+	featureJSONAPI:      {Default: false, Stage: "Alpha"},
+	featureNotifiers:    {Default: false, Stage: "Alpha"},
+}
+
+// features is set once on start from flag `feature-gates`.
+var features = map[string]bool{}
+
+func parseFeatureGates(s string) (map[string]bool, error) {
+	ret := map[string]bool{}
+	for name, g := range featureGates {
+		ret[name] = g.Default
+	}
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		p := strings.SplitN(kv, "=", 2)
+		if _, ok := featureGates[p[0]]; !ok {
+			return nil, fmt.Errorf("unknown feature gate `%s`, known are %s", p[0], strings.Join(featureNames(), ", "))
+		}
+		if len(p) != 2 {
+			return nil, fmt.Errorf("feature gate `%s` needs a value, true or false", p[0])
+		}
+		v, err := strconv.ParseBool(p[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value `%s` of feature gate `%s`", p[1], p[0])
+		}
+		ret[p[0]] = v
+	}
+	return ret, nil
+}
+
+func featureNames() []string {
+	ret := make([]string, 0, len(featureGates))
+	for name := range featureGates {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func loadFeatureGates() error {
+	fs, err := parseFeatureGates(*featureGatesFlag)
+	if err != nil {
+		return err
+	}
+	features = fs
+	for name, enabled := range fs {
+		featureEnabledGauge.WithLabelValues(name, featureGates[name].Stage).Set(gaugeBool(enabled))
+	}
+	return nil
+}
+
+func featureEnabled(name string) bool {
+	if v, ok := features[name]; ok {
+		return v
+	}
+	return featureGates[name].Default
+}
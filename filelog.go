@@ -0,0 +1,179 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+var logFile = flag.String("logFile", "", "File to append newline-delimited JSON to with -loggingTo=file.")
+var logFileMaxSize = flag.Int("logFileMaxSize", defaultLogFileMaxSize, "Size in megabytes at which logFile is rotated.")
+var logFileCompress = flag.Bool("logFileCompress", defaultLogFileCompress, "Gzip rotated log files.")
+var logFileMaxAge = flag.Duration("logFileMaxAge", 0, "Delete rotated log files older than this. Kept forever when 0.")
+var logFileMaxCount = flag.Int("logFileMaxCount", 0, "Number of rotated log files to keep. All are kept when 0.")
+
+// rotatedLogTimeFormat sorts like time and is valid in file names.
+const rotatedLogTimeFormat = "20060102T150405.000"
+
+// jsonlFile appends lines to logFile, renaming it to
+// <logFile>.<time>[.gz] when it grows beyond logFileMaxSize.
+type jsonlFile struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+var logFileWriter = &jsonlFile{}
+
+func (j *jsonlFile) open() error {
+	f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.f = f
+	j.size = st.Size()
+	return nil
+}
+
+// writeLines writes each line followed by a newline. A line is never split
+// across files.
+func (j *jsonlFile) writeLines(lines ...string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, l := range lines {
+		if j.f == nil {
+			if err := j.open(); err != nil {
+				return err
+			}
+		}
+		n, err := io.WriteString(j.f, l+"\n")
+		j.size += int64(n)
+		if err != nil {
+			return err
+		}
+		if j.size >= int64(*logFileMaxSize)<<20 {
+			if err := j.rotate(time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// close closes logFile on shutdown. It is reopened by the next write.
+func (j *jsonlFile) close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return
+	}
+	if err := j.f.Close(); err != nil {
+		log.Errorf("close %s: %v", *logFile, err)
+	}
+	j.f = nil
+}
+
+func (j *jsonlFile) rotate(now time.Time) error {
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	j.f = nil
+	rotated := *logFile + "." + now.UTC().Format(rotatedLogTimeFormat)
+	if err := os.Rename(*logFile, rotated); err != nil {
+		return err
+	}
+	if *logFileCompress {
+		if err := gzipFile(rotated); err != nil {
+			log.Errorf("compress %s: %v", rotated, err)
+		}
+	}
+	return pruneRotatedLogs(now)
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// pruneRotatedLogs deletes the rotated files beyond logFileMaxCount and
+// those older than logFileMaxAge, by the time in their names.
+func pruneRotatedLogs(now time.Time) error {
+	if *logFileMaxCount <= 0 && *logFileMaxAge <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(*logFile + ".*")
+	if err != nil {
+		return err
+	}
+	type rotatedLog struct {
+		path string
+		time time.Time
+	}
+	rotated := []rotatedLog{}
+	for _, p := range matches {
+		s := strings.TrimSuffix(strings.TrimPrefix(p, *logFile+"."), ".gz")
+		t, err := time.Parse(rotatedLogTimeFormat, s)
+		if err != nil {
+			continue
+		}
+		rotated = append(rotated, rotatedLog{p, t})
+	}
+	sort.Slice(rotated, func(a, b int) bool { return rotated[a].time.After(rotated[b].time) })
+	for i, r := range rotated {
+		if (*logFileMaxCount > 0 && i >= *logFileMaxCount) || (*logFileMaxAge > 0 && now.Sub(r.time) > *logFileMaxAge) {
+			if err := os.Remove(r.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateLogFileFlags() error {
+	if *loggingTo != "file" {
+		return nil
+	}
+	if *logFile == "" {
+		return fmt.Errorf("flag `logFile` is needed with `loggingTo=file`")
+	}
+	if *logFileMaxSize <= 0 {
+		return fmt.Errorf("invalid value `%d` of flag `logFileMaxSize`", *logFileMaxSize)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLFileRotate(t *testing.T) {
+	defer setFlagValues(flagValues())
+	line := strings.Repeat("x", 1<<20)
+	for _, c := range []struct {
+		name     string
+		compress bool
+		maxCount int
+		maxAge   time.Duration
+		// ages of the files rotated before
+		old []time.Duration
+		// ages of the old files kept
+		kept []time.Duration
+	}{
+		{"keep all", false, 0, 0, []time.Duration{time.Hour, 48 * time.Hour}, []time.Duration{time.Hour, 48 * time.Hour}},
+		{"compress", true, 0, 0, nil, nil},
+		{"max count", false, 2, 0, []time.Duration{time.Minute, time.Hour, 48 * time.Hour}, []time.Duration{time.Minute}},
+		{"max age", true, 0, 24 * time.Hour, []time.Duration{time.Hour, 48 * time.Hour}, []time.Duration{time.Hour}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "filelog")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			*logFile = filepath.Join(dir, "hpa.jsonl")
+			*logFileMaxSize = 1
+			*logFileCompress = c.compress
+			*logFileMaxCount = c.maxCount
+			*logFileMaxAge = c.maxAge
+			now := time.Now()
+			oldName := func(age time.Duration) string {
+				name := *logFile + "." + now.Add(-age).UTC().Format(rotatedLogTimeFormat)
+				if c.compress {
+					name += ".gz"
+				}
+				return name
+			}
+			for _, age := range c.old {
+				if err := ioutil.WriteFile(oldName(age), []byte("old\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			j := &jsonlFile{}
+			if err := j.writeLines(`{"small":true}`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(*logFile); err != nil {
+				t.Fatalf("not written below logFileMaxSize: %v", err)
+			}
+			if err := j.writeLines(line); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(*logFile); !os.IsNotExist(err) {
+				t.Fatalf("%s not rotated: %v", *logFile, err)
+			}
+
+			want := []string{}
+			for _, age := range c.kept {
+				want = append(want, oldName(age))
+			}
+			rotated := ""
+			matches, _ := filepath.Glob(*logFile + ".*")
+			got := []string{}
+			for _, m := range matches {
+				if isOld(m, c.old, oldName) {
+					got = append(got, m)
+					continue
+				}
+				if rotated != "" {
+					t.Fatalf("rotated twice: %s and %s", rotated, m)
+				}
+				rotated = m
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("kept %v, want %v", got, want)
+			}
+			if rotated == "" {
+				t.Fatal("no rotated file")
+			}
+			if strings.HasSuffix(rotated, ".gz") != c.compress {
+				t.Errorf("rotated to %s, compressed %v", rotated, c.compress)
+			}
+			ts := strings.TrimSuffix(strings.TrimPrefix(rotated, *logFile+"."), ".gz")
+			if _, err := time.Parse(rotatedLogTimeFormat, ts); err != nil {
+				t.Errorf("rotated to %s: %v", rotated, err)
+			}
+			if got := readLog(t, rotated, c.compress); got != `{"small":true}`+"\n"+line+"\n" {
+				t.Errorf("rotated file has %d bytes, want both lines", len(got))
+			}
+		})
+	}
+}
+
+func isOld(path string, ages []time.Duration, name func(time.Duration) string) bool {
+	for _, age := range ages {
+		if name(age) == path {
+			return true
+		}
+	}
+	return false
+}
+
+func readLog(t *testing.T, path string, compressed bool) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !compressed {
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
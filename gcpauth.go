@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var gcpCredentialsFile = flag.String("gcpCredentialsFile", "", "Service account key file for the Google Cloud sinks. $GOOGLE_APPLICATION_CREDENTIALS, then the metadata server (e.g. GKE Workload Identity) are used when empty.")
+
+const gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1/"
+
+// gcpClient gets tokens and calls the Google APIs.
+var gcpClient = &http.Client{Timeout: 30 * time.Second}
+
+type gcpServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	ProjectID   string `json:"project_id"`
+}
+
+// gcpTokenSource gets OAuth2 access tokens for Google APIs, either from a
+// service account key or from the metadata server, and caches them until
+// shortly before they expire.
+type gcpTokenSource struct {
+	scopes []string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newGCPTokenSource(scopes ...string) *gcpTokenSource {
+	return &gcpTokenSource{scopes: scopes}
+}
+
+type gcpTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func gcpCredentialsPath() string {
+	if *gcpCredentialsFile != "" {
+		return *gcpCredentialsFile
+	}
+	return os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+}
+
+func (s *gcpTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expiry) {
+		return s.token, nil
+	}
+	var res *http.Response
+	var err error
+	if path := gcpCredentialsPath(); path != "" {
+		res, err = s.exchangeJWT(path)
+	} else {
+		req, _ := http.NewRequest(http.MethodGet, gcpMetadataURL+"instance/service-accounts/default/token?scopes="+url.QueryEscape(strings.Join(s.scopes, ",")), nil)
+		req.Header.Set("Metadata-Flavor", "Google")
+		res, err = gcpClient.Do(req)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get google access token: %s: %s", res.Status, b)
+	}
+	t := gcpTokenResponse{}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return "", err
+	}
+	s.token = t.AccessToken
+	s.expiry = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+// gcpMetadata reads a value from the metadata server, e.g.
+// instance/attributes/cluster-name on GKE.
+func gcpMetadata(path string) (string, error) {
+	req, _ := http.NewRequest(http.MethodGet, gcpMetadataURL+path, nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get metadata %s: %s", path, res.Status)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func readGCPServiceAccount(path string) (*gcpServiceAccount, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sa := &gcpServiceAccount{}
+	if err := json.Unmarshal(b, sa); err != nil {
+		return nil, err
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return sa, nil
+}
+
+// exchangeJWT gets a token with a JWT signed by the service account key.
+func (s *gcpTokenSource) exchangeJWT(path string) (*http.Response, error) {
+	sa, err := readGCPServiceAccount(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, errors.New("no private key in " + path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key in " + path + " is not an RSA key")
+	}
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   sa.ClientEmail,
+		"scope": strings.Join(s.scopes, " "),
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return gcpClient.PostForm(sa.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	})
+}
+
+// gcpDo sends a JSON request to a Google API and decodes the response into
+// out unless it is nil.
+func gcpDo(ts *gcpTokenSource, method, url string, in, out interface{}) (int, error) {
+	token, err := ts.Token()
+	if err != nil {
+		return 0, err
+	}
+	var body []byte
+	if in != nil {
+		if body, err = json.Marshal(in); err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequest(method, url, strings.NewReader(string(body)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := gcpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode/100 != 2 {
+		return res.StatusCode, fmt.Errorf("%s %s: %s: %s", method, url, res.Status, b)
+	}
+	if out != nil {
+		return res.StatusCode, json.Unmarshal(b, out)
+	}
+	return res.StatusCode, nil
+}
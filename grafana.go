@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+)
+
+var grafanaDashboardTitle = flag.String("grafanaDashboardTitle", defaultGrafanaDashboardTitle, "Title of the generated Grafana dashboard.")
+
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+type grafanaPanel struct {
+	ID         int             `json:"id"`
+	Title      string          `json:"title"`
+	Type       string          `json:"type"`
+	Datasource string          `json:"datasource"`
+	GridPos    map[string]int  `json:"gridPos"`
+	Targets    []grafanaTarget `json:"targets"`
+}
+
+type grafanaVariable struct {
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	Datasource string `json:"datasource,omitempty"`
+	Query      string `json:"query"`
+	Refresh    int    `json:"refresh"`
+	Multi      bool   `json:"multi"`
+	IncludeAll bool   `json:"includeAll"`
+}
+
+type grafanaDashboard struct {
+	Title         string       `json:"title"`
+	UID           string       `json:"uid"`
+	Tags          []string     `json:"tags"`
+	SchemaVersion int          `json:"schemaVersion"`
+	Refresh       string       `json:"refresh"`
+	Time          grafanaRange `json:"time"`
+	Templating    struct {
+		List []grafanaVariable `json:"list"`
+	} `json:"templating"`
+	Panels []grafanaPanel `json:"panels"`
+}
+
+type grafanaRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func grafanaDashboardModel() grafanaDashboard {
+	const ds = "${datasource}"
+	sel := `hpa_namespace=~"$namespace",hpa_name=~"$hpa"`
+	series := "{{hpa_namespace}}/{{hpa_name}}"
+
+	d := grafanaDashboard{
+		Title:         *grafanaDashboardTitle,
+		UID:           "hpa-exporter",
+		Tags:          []string{"kubernetes", "hpa"},
+		SchemaVersion: 16,
+		Refresh:       "1m",
+		Time:          grafanaRange{From: "now-6h", To: "now"},
+	}
+	d.Templating.List = []grafanaVariable{
+		{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		{Name: "namespace", Label: "Namespace", Type: "query", Datasource: ds, Query: "label_values(hpa_max_pods_num, hpa_namespace)", Refresh: 2, Multi: true, IncludeAll: true},
+		{Name: "hpa", Label: "HPA", Type: "query", Datasource: ds, Query: `label_values(hpa_max_pods_num{hpa_namespace=~"$namespace"}, hpa_name)`, Refresh: 2, Multi: true, IncludeAll: true},
+	}
+
+	panel := func(id int, title string, y int, targets ...grafanaTarget) grafanaPanel {
+		for i := range targets {
+			targets[i].RefID = string(rune('A' + i))
+		}
+		return grafanaPanel{
+			ID:         id,
+			Title:      title,
+			Type:       "graph",
+			Datasource: ds,
+			GridPos:    map[string]int{"h": 8, "w": 24, "x": 0, "y": y},
+			Targets:    targets,
+		}
+	}
+	d.Panels = []grafanaPanel{
+		panel(1, "Replicas", 0,
+			grafanaTarget{Expr: fmt.Sprintf("hpa_current_pods_num{%s}", sel), LegendFormat: "current " + series},
+			grafanaTarget{Expr: fmt.Sprintf("hpa_desired_pods_num{%s}", sel), LegendFormat: "desired " + series},
+			grafanaTarget{Expr: fmt.Sprintf("hpa_min_pods_num{%s}", sel), LegendFormat: "min " + series},
+			grafanaTarget{Expr: fmt.Sprintf("hpa_max_pods_num{%s}", sel), LegendFormat: "max " + series},
+		),
+		panel(2, "Current / target metrics", 8,
+			grafanaTarget{Expr: fmt.Sprintf("hpa_current_metrics_value{%s}", sel), LegendFormat: "current " + series + " {{metric_kind}} {{metric_name}} {{metric_metricname}}"},
+			grafanaTarget{Expr: fmt.Sprintf("hpa_target_metrics_value{%s}", sel), LegendFormat: "target " + series + " {{metric_kind}} {{metric_name}} {{metric_metricname}}"},
+		),
+		panel(3, "Conditions not satisfied", 16,
+			grafanaTarget{Expr: fmt.Sprintf(`hpa_able_to_scale{%s,cond_status="False"} == 1`, sel), LegendFormat: "AbleToScale " + series + " {{cond_reason}}"},
+			grafanaTarget{Expr: fmt.Sprintf(`hpa_scaling_active{%s,cond_status="False"} == 1`, sel), LegendFormat: "ScalingActive " + series + " {{cond_reason}}"},
+			grafanaTarget{Expr: fmt.Sprintf(`hpa_scaling_limited{%s,cond_status="True"} == 1`, sel), LegendFormat: "ScalingLimited " + series + " {{cond_reason}}"},
+		),
+		panel(4, "Seconds since last scale", 24,
+			grafanaTarget{Expr: fmt.Sprintf("time() - hpa_last_scale_second{%s}", sel), LegendFormat: series},
+		),
+	}
+	return d
+}
+
+func grafanaDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(grafanaDashboardModel())
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type dependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyStatus `json:"dependencies,omitempty"`
+}
+
+func healthyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("deep") != "true" {
+		w.Write([]byte("OK\n"))
+		return
+	}
+
+	checks := []check{
+		{"kubernetes", func() error {
+			for _, ns := range listedNamespaces() {
+				_, err := kubeClient.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).List(meta_v1.ListOptions{Limit: 1})
+				if errors.IsNotFound(err) {
+					// autoscaling/v1 only cluster
+					_, err = kubeClient.AutoscalingV1().HorizontalPodAutoscalers(ns).List(meta_v1.ListOptions{Limit: 1})
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	}
+	if *conditionLogging && *loggingTo == "cwlogs" {
+		checks = append(checks, check{"cloudwatchlogs", func() error {
+			_, err := cwClient().DescribeLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+				LogGroupName:        cwLogGroup,
+				LogStreamNamePrefix: cwLogStream,
+			})
+			return err
+		}})
+	}
+
+	report := healthReport{
+		Status:       "ok",
+		Dependencies: map[string]dependencyStatus{},
+	}
+	code := http.StatusOK
+	for _, c := range checks {
+		if err := c.Run(); err != nil {
+			report.Dependencies[c.Name] = dependencyStatus{Status: "error", Error: err.Error()}
+			report.Status = "error"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		report.Dependencies[c.Name] = dependencyStatus{Status: "ok"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
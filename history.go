@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var historyFile = flag.String("historyFile", "", "File to keep the replica and metric history of HPAs in across restarts. The history is kept in memory only when empty.")
+var historyRetention = flag.Duration("historyRetention", defaultHistoryRetention, "How long the history of HPAs is kept.")
+
+// history samples are kept at most once per minute, like demand samples.
+const historyResolution = time.Minute
+
+type historySample struct {
+	Time           time.Time          `json:"time"`
+	Current        int32              `json:"current"`
+	Desired        int32              `json:"desired"`
+	Min            int32              `json:"min"`
+	Max            int32              `json:"max"`
+	Demand         float64            `json:"demand"`
+	CurrentMetrics map[string]float64 `json:"currentMetrics,omitempty"`
+	TargetMetrics  map[string]float64 `json:"targetMetrics,omitempty"`
+}
+
+type historyRecord struct {
+	HPA    string        `json:"hpa"`
+	Sample historySample `json:"sample"`
+}
+
+// historyStore is an append-only file of samples, rewritten without the
+// expired samples once an hour.
+type historyStore struct {
+	mu          sync.RWMutex
+	samples     map[string][]historySample
+	file        *os.File
+	compactedAt time.Time
+}
+
+var hpaHistoryStore = &historyStore{samples: map[string][]historySample{}}
+
+func openHistoryStore(path string) error {
+	s := hpaHistoryStore
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	cut := time.Now().Add(-*historyRetention)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		r := historyRecord{}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			// a partially written last line
+			continue
+		}
+		if r.Sample.Time.After(cut) {
+			s.samples[r.HPA] = append(s.samples[r.HPA], r.Sample)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	return s.compact(time.Now())
+}
+
+// compact drops expired samples from memory and rewrites the file. It is
+// called with mu held.
+func (s *historyStore) compact(now time.Time) error {
+	cut := now.Add(-*historyRetention)
+	for k, samples := range s.samples {
+		i := 0
+		for i < len(samples) && samples[i].Time.Before(cut) {
+			i++
+		}
+		if i == len(samples) {
+			delete(s.samples, k)
+		} else {
+			s.samples[k] = samples[i:]
+		}
+	}
+	s.compactedAt = now
+	if s.file == nil {
+		return nil
+	}
+	path := s.file.Name()
+	tmp, err := os.Create(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for k, samples := range s.samples {
+		for _, sample := range samples {
+			enc.Encode(historyRecord{k, sample})
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0600)
+	return err
+}
+
+func (s *historyStore) add(key string, sample historySample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := s.samples[key]
+	if n := len(samples); n > 0 && sample.Time.Sub(samples[n-1].Time) < historyResolution {
+		return
+	}
+	s.samples[key] = append(samples, sample)
+	if s.file != nil {
+		b, _ := json.Marshal(historyRecord{key, sample})
+		if _, err := s.file.Write(append(b, '\n')); err != nil {
+			log.Errorln("write history:", err)
+		}
+	}
+	if sample.Time.Sub(s.compactedAt) >= time.Hour {
+		if err := s.compact(sample.Time); err != nil {
+			log.Errorln("compact history:", err)
+		}
+	}
+}
+
+func (s *historyStore) get(key string, since time.Time) []historySample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := []historySample{}
+	for _, sample := range s.samples[key] {
+		if !sample.Time.Before(since) {
+			ret = append(ret, sample)
+		}
+	}
+	return ret
+}
+
+func recordHistory(a as_v2.HorizontalPodAutoscaler, now time.Time) {
+	sample := historySample{
+		Time:           now,
+		Current:        a.Status.CurrentReplicas,
+		Desired:        a.Status.DesiredReplicas,
+		Min:            minReplicas(a),
+		Max:            a.Spec.MaxReplicas,
+		Demand:         demandReplicas(a),
+		CurrentMetrics: map[string]float64{},
+		TargetMetrics:  map[string]float64{},
+	}
+	for _, m := range statusMetrics(a) {
+		sample.CurrentMetrics[metricKey(m)] = m.Value
+	}
+	for _, m := range specMetrics(a) {
+		sample.TargetMetrics[metricKey(m)] = m.Value
+	}
+	hpaHistoryStore.add(hpaKey(a), sample)
+}
+
+// storedDemand seeds the demand of an HPA seen for the first time since
+// start, so recommendations survive restarts.
+func storedDemand(key string, since time.Time) []demandSample {
+	ret := []demandSample{}
+	for _, s := range hpaHistoryStore.get(key, since) {
+		ret = append(ret, demandSample{s.Time, s.Demand})
+	}
+	return ret
+}
+
+// hpaHistoryHandler serves /api/v1/hpas/{namespace}/{name}/history.
+func hpaHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	p := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/hpas/"), "/")
+	if len(p) != 3 || p[2] != "history" {
+		http.NotFound(w, r)
+		return
+	}
+	key := p[0] + "/" + p[1]
+	window := *historyRetention
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "invalid window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	hpa, err := apiHpaList(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	found := false
+	for _, a := range hpa {
+		if hpaKey(a) == key {
+			found = true
+			break
+		}
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hpaHistoryStore.get(key, time.Now().Add(-window)))
+}
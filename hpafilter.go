@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var includeHpaRegex = flag.String("include-hpa-regex", "", "Regular expression of the names of the HPAs to export and log. All HPAs when empty.")
+var excludeHpaRegex = flag.String("exclude-hpa-regex", "", "Regular expression of the names of the HPAs not to export and log, e.g. `-canary$`.")
+
+// compileHpaRegexes returns nil for empty expressions.
+func compileHpaRegexes(include, exclude string) (inc, exc *regexp.Regexp, err error) {
+	if include != "" {
+		if inc, err = regexp.Compile(include); err != nil {
+			return nil, nil, err
+		}
+	}
+	if exclude != "" {
+		if exc, err = regexp.Compile(exclude); err != nil {
+			return nil, nil, err
+		}
+	}
+	return inc, exc, nil
+}
+
+func filterHpaNames(hpa []as_v2.HorizontalPodAutoscaler) []as_v2.HorizontalPodAutoscaler {
+	c := currentAppliedConfig()
+	include, exclude := c.include, c.exclude
+	if include == nil && exclude == nil {
+		return hpa
+	}
+	ret := make([]as_v2.HorizontalPodAutoscaler, 0, len(hpa))
+	for _, a := range hpa {
+		if include != nil && !include.MatchString(a.ObjectMeta.Name) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(a.ObjectMeta.Name) {
+			continue
+		}
+		ret = append(ret, a)
+	}
+	return ret
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	authn_v1 "k8s.io/api/authentication/v1"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var apiImpersonate = flag.Bool("apiImpersonate", defaultAPIImpersonate, "List HPAs for the /api/ endpoints as the requesting user, so callers only see the namespaces they may list HPAs in. Needs authMode kubernetes.")
+
+type kubeUserContextKey struct{}
+
+func withKubeUser(r *http.Request, u authn_v1.UserInfo) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), kubeUserContextKey{}, u))
+}
+
+// impersonatingClientTTL is how long a client of a user is reused, which
+// keeps its connections and is short enough to not pile up clients of users
+// who are gone.
+const impersonatingClientTTL = 5 * time.Minute
+
+type cachedClient struct {
+	client  kubernetes.Interface
+	expires time.Time
+}
+
+var (
+	impersonatingClientsMu sync.Mutex
+	impersonatingClients   = map[string]cachedClient{}
+)
+
+func impersonatingClient(u authn_v1.UserInfo) (kubernetes.Interface, error) {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	key := string(b)
+	now := time.Now()
+	impersonatingClientsMu.Lock()
+	defer impersonatingClientsMu.Unlock()
+	if c, ok := impersonatingClients[key]; ok && now.Before(c.expires) {
+		return c.client, nil
+	}
+	for k, c := range impersonatingClients {
+		if !now.Before(c.expires) {
+			delete(impersonatingClients, k)
+		}
+	}
+	config, err := newKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	extra := map[string][]string{}
+	for k, v := range u.Extra {
+		extra[k] = v
+	}
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: u.Username,
+		Groups:   u.Groups,
+		Extra:    extra,
+	}
+	c, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	impersonatingClients[key] = cachedClient{c, now.Add(impersonatingClientTTL)}
+	return c, nil
+}
+
+// impersonatedHpaList lists HPAs as the user, with the shard and name
+// filters of the exporter applied. Users who cannot list HPAs in all
+// namespaces get those of the namespaces they can list.
+func impersonatedHpaList(u authn_v1.UserInfo) ([]as_v2.HorizontalPodAutoscaler, error) {
+	c, err := impersonatingClient(u)
+	if err != nil {
+		return nil, err
+	}
+	hpa, err := listHpasAs(c)
+	if err != nil {
+		return nil, err
+	}
+	return filterShard(filterHpaNames(hpa)), nil
+}
+
+func listHpasAs(c kubernetes.Interface) ([]as_v2.HorizontalPodAutoscaler, error) {
+	all := []as_v2.HorizontalPodAutoscaler{}
+	var err error
+	for _, ns := range listedNamespaces() {
+		var l []as_v2.HorizontalPodAutoscaler
+		if l, _, _, err = listHpasWithFallbackAs(c, ns); err != nil {
+			break
+		}
+		all = append(all, l...)
+	}
+	if err == nil {
+		return all, nil
+	}
+	if !errors.IsForbidden(err) {
+		return nil, err
+	}
+	namespaces := map[string]bool{}
+	for _, a := range cachedHpaList() {
+		namespaces[a.ObjectMeta.Namespace] = true
+	}
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	ret := []as_v2.HorizontalPodAutoscaler{}
+	for _, ns := range names {
+		l, _, _, err := listHpasWithFallbackAs(c, ns)
+		if errors.IsForbidden(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, l...)
+	}
+	return ret, nil
+}
+
+// apiHpaList returns the HPAs the /api/ endpoints serve to the request.
+func apiHpaList(r *http.Request) ([]as_v2.HorizontalPodAutoscaler, error) {
+	if !*apiImpersonate {
+		return cachedHpaList(), nil
+	}
+	u, ok := r.Context().Value(kubeUserContextKey{}).(authn_v1.UserInfo)
+	if !ok {
+		return nil, fmt.Errorf("request is not authenticated")
+	}
+	return impersonatedHpaList(u)
+}
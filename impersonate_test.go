@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/buildsville/hpa-exporter/pkg/collector/collectortest"
+	authn_v1 "k8s.io/api/authentication/v1"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+func TestImpersonatedHpaListFilters(t *testing.T) {
+	defer loadAppliedConfig()
+	defer setFlagValues(flagValues())
+	hpa := []as_v2.HorizontalPodAutoscaler{}
+	for _, name := range []string{"web", "web-canary", "api", "worker"} {
+		a := as_v2.HorizontalPodAutoscaler{}
+		a.ObjectMeta.Namespace, a.ObjectMeta.Name = "prod", name
+		hpa = append(hpa, a)
+	}
+	u := authn_v1.UserInfo{Username: "alice", Groups: []string{"dev"}}
+	b, _ := json.Marshal(u)
+	impersonatingClientsMu.Lock()
+	impersonatingClients[string(b)] = cachedClient{collectortest.NewClient(hpa...), time.Now().Add(time.Minute)}
+	impersonatingClientsMu.Unlock()
+
+	// api is not included, web-canary excluded and web in shard 2
+	*includeHpaRegex = "^w"
+	*excludeHpaRegex = "-canary$"
+	*shardCount, *shardIndex, *shardBy = 3, 0, "name"
+	if err := loadAppliedConfig(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := impersonatedHpaList(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ObjectMeta.Name != "worker" {
+		t.Errorf("got %v, want worker", got)
+	}
+}
+
+func TestImpersonatingClientCached(t *testing.T) {
+	u := authn_v1.UserInfo{Username: "bob"}
+	b, _ := json.Marshal(u)
+	c := collectortest.NewClient()
+	impersonatingClientsMu.Lock()
+	impersonatingClients[string(b)] = cachedClient{c, time.Now().Add(time.Minute)}
+	impersonatingClients["gone"] = cachedClient{c, time.Now().Add(-time.Second)}
+	impersonatingClientsMu.Unlock()
+	got, err := impersonatingClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Error("a client that has not expired is not reused")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+var collectJitter = flag.Duration("collectJitter", defaultCollectJitter, "Delay collections by a fixed offset between 0 and this, derived from -collectJitterSeed, so that many exporters do not hit their API servers at the same second.")
+var collectJitterSeed = flag.String("collectJitterSeed", "", "Seed of the -collectJitter offset, e.g. the cluster name. Defaults to the host name.")
+var collectAlign = flag.Bool("collectAlign", defaultCollectAlign, "Start collections at multiples of metricsInterval since the epoch (e.g. :00 and :30 with 30), plus the -collectJitter offset.")
+
+// jitterOffset returns the same offset in [0, collectJitter) for the same
+// seed.
+func jitterOffset() time.Duration {
+	if *collectJitter <= 0 {
+		return 0
+	}
+	seed := *collectJitterSeed
+	if seed == "" {
+		seed, _ = os.Hostname()
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return time.Duration(h.Sum64() % uint64(*collectJitter))
+}
+
+// nextCollection returns when to collect after a collection at now.
+func nextCollection(now time.Time) time.Time {
+	interval := time.Duration(*metricsInterval) * time.Second
+	if !*collectAlign {
+		return now.Add(interval)
+	}
+	next := now.Truncate(interval).Add(jitterOffset())
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+	return next
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+	authn_v1 "k8s.io/api/authentication/v1"
+	authz_v1 "k8s.io/api/authorization/v1"
+)
+
+var authMode = flag.String("authMode", defaultAuthMode, "How HTTP requests are authorized. (none or kubernetes) kubernetes validates the bearer token with a TokenReview and authorizes the request path with a SubjectAccessReview.")
+var authCacheTTL = flag.Duration("authCacheTTL", defaultAuthCacheTTL, "Duration TokenReview and SubjectAccessReview results are cached for.")
+
+// unauthenticatedPaths are served without authorization so that probes
+// keep working.
+var unauthenticatedPaths = map[string]bool{
+	"/-/healthy": true,
+}
+
+type authDecision struct {
+	Allowed bool
+	User    authn_v1.UserInfo
+	Reason  string
+	Expires time.Time
+}
+
+var (
+	authCacheMu sync.Mutex
+	authCache   = map[string]authDecision{}
+)
+
+// requestVerb maps the HTTP method to the verb of a non-resource request,
+// as the API server does.
+func requestVerb(method string) string {
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "get"
+	}
+}
+
+var errUnauthenticated = errors.New("unauthenticated")
+
+func reviewRequest(token, path, verb string) (authDecision, error) {
+	tr, err := kubeClient.AuthenticationV1().TokenReviews().Create(&authn_v1.TokenReview{
+		Spec: authn_v1.TokenReviewSpec{Token: token},
+	})
+	if err != nil {
+		return authDecision{}, err
+	}
+	if !tr.Status.Authenticated {
+		return authDecision{}, errUnauthenticated
+	}
+	u := tr.Status.User
+	extra := map[string]authz_v1.ExtraValue{}
+	for k, v := range u.Extra {
+		extra[k] = authz_v1.ExtraValue(v)
+	}
+	sar, err := kubeClient.AuthorizationV1().SubjectAccessReviews().Create(&authz_v1.SubjectAccessReview{
+		Spec: authz_v1.SubjectAccessReviewSpec{
+			User:   u.Username,
+			Groups: u.Groups,
+			UID:    u.UID,
+			Extra:  extra,
+			NonResourceAttributes: &authz_v1.NonResourceAttributes{
+				Path: path,
+				Verb: verb,
+			},
+		},
+	})
+	if err != nil {
+		return authDecision{}, err
+	}
+	reason := sar.Status.Reason
+	if !sar.Status.Allowed && reason == "" {
+		reason = fmt.Sprintf("user %s cannot %s %s", u.Username, verb, path)
+	}
+	return authDecision{Allowed: sar.Status.Allowed, User: u, Reason: reason}, nil
+}
+
+func authorizeRequest(token, path, verb string) (authDecision, error) {
+	key := verb + " " + path + " " + token
+	now := time.Now()
+	authCacheMu.Lock()
+	d, ok := authCache[key]
+	authCacheMu.Unlock()
+	if ok && now.Before(d.Expires) {
+		return d, nil
+	}
+	d, err := reviewRequest(token, path, verb)
+	if err != nil {
+		return d, err
+	}
+	d.Expires = now.Add(*authCacheTTL)
+	authCacheMu.Lock()
+	for k, e := range authCache {
+		if now.After(e.Expires) {
+			delete(authCache, k)
+		}
+	}
+	authCache[key] = d
+	authCacheMu.Unlock()
+	return d, nil
+}
+
+func withKubernetesAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticatedPaths[r.URL.Path] || r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == r.Header.Get("Authorization") {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		d, err := authorizeRequest(token, r.URL.Path, requestVerb(r.Method))
+		if err == errUnauthenticated {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Errorln("authorize request:", err)
+			http.Error(w, "authorization failed", http.StatusInternalServerError)
+			return
+		}
+		setAuditIdentity(r, d.User.Username)
+		if !d.Allowed {
+			http.Error(w, "forbidden: "+d.Reason, http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, withKubeUser(r, d.User))
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/url"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/metrics"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+type kubeLatencyMetric struct{}
+
+func (kubeLatencyMetric) Observe(verb string, u url.URL, latency time.Duration) {
+	// u is the template of the request URL with {namespace} and {name}
+	kubeAPIRequestDuration.WithLabelValues(verb, u.Path).Observe(latency.Seconds())
+}
+
+type kubeResultMetric struct{}
+
+func (kubeResultMetric) Increment(code, method, host string) {
+	kubeAPIRequestsTotal.WithLabelValues(code, method, host).Inc()
+}
+
+func init() {
+	metrics.Register(kubeLatencyMetric{}, kubeResultMetric{})
+}
+
+// throttleObserver measures how long requests wait for the client side
+// rate limiter, which client-go leaves out of the request latency.
+type throttleObserver struct {
+	flowcontrol.RateLimiter
+}
+
+func (t throttleObserver) Accept() {
+	start := time.Now()
+	t.RateLimiter.Accept()
+	kubeAPIRateLimiterWait.Observe(time.Since(start).Seconds())
+}
+
+func instrumentRateLimiter(config *rest.Config) {
+	if config.RateLimiter != nil {
+		config.RateLimiter = throttleObserver{config.RateLimiter}
+		return
+	}
+	qps, burst := config.QPS, config.Burst
+	if qps == 0 {
+		qps = rest.DefaultQPS
+	}
+	if burst == 0 {
+		burst = rest.DefaultBurst
+	}
+	config.RateLimiter = throttleObserver{flowcontrol.NewTokenBucketRateLimiter(qps, burst)}
+}
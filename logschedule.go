@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var loggingScheduleFile = flag.String("loggingSchedule", "", "YAML file of cron windows in which condition logging is less frequent or off, e.g. outside business hours.")
+
+// loggingWindow logs conditions at most every Interval while it is open,
+// not at all when Interval is 0.
+type loggingWindow struct {
+	cronWindow `yaml:",inline"`
+	Interval   time.Duration `yaml:"interval"`
+}
+
+var (
+	loggingWindowsMu sync.RWMutex
+	loggingWindows   []*loggingWindow
+)
+
+func loadLoggingSchedule(path string) error {
+	ws, err := parseLoggingSchedule(path)
+	if err != nil {
+		return err
+	}
+	setLoggingWindows(ws)
+	return nil
+}
+
+func parseLoggingSchedule(path string) ([]*loggingWindow, error) {
+	ws := []*loggingWindow{}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(b, &ws); err != nil {
+			return nil, fmt.Errorf("parse logging schedule %s: %v", path, err)
+		}
+	}
+	for _, w := range ws {
+		if err := w.compile(); err != nil {
+			return nil, err
+		}
+		if w.Interval < 0 {
+			return nil, fmt.Errorf("negative interval of schedule `%s`", w.Schedule)
+		}
+	}
+	return ws, nil
+}
+
+func setLoggingWindows(ws []*loggingWindow) {
+	loggingWindowsMu.Lock()
+	loggingWindows = ws
+	loggingWindowsMu.Unlock()
+}
+
+// scheduledLoggingInterval returns the interval of the open windows, the
+// longest when several are open and 0 when one turns logging off. ok is
+// false when no window is open.
+func scheduledLoggingInterval(now time.Time) (interval time.Duration, ok bool) {
+	loggingWindowsMu.RLock()
+	defer loggingWindowsMu.RUnlock()
+	for _, w := range loggingWindows {
+		if !w.Active(now) {
+			continue
+		}
+		if w.Interval == 0 {
+			return 0, true
+		}
+		if !ok || w.Interval > interval {
+			interval = w.Interval
+		}
+		ok = true
+	}
+	return interval, ok
+}
+
+// loggingDue tells whether conditions are logged now given when they were
+// last logged. Changes are kept until the next time they are logged.
+func loggingDue(now, last time.Time) bool {
+	interval, ok := scheduledLoggingInterval(now)
+	conditionLoggingThrottled.Set(0)
+	if !ok {
+		return true
+	}
+	conditionLoggingThrottled.Set(1)
+	return interval > 0 && now.Sub(last) >= interval
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+	as_v1 "k8s.io/api/autoscaling/v1"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var namespaces = flag.String("namespaces", "", "Comma separated namespaces whose HPAs are each listed and watched on their own, so only namespaced permissions are needed.")
+var watchStaleAfter = flag.Duration("watchStaleAfter", defaultWatchStaleAfter, "Rebuild the HPA watch of a namespace of -namespaces that has HPAs but has not delivered an update for this long. Disabled when 0.")
+
+// listedNamespaces returns the namespaces to make API calls in, "" being
+// all namespaces.
+func listedNamespaces() []string {
+	if *namespaces == "" {
+		return []string{*watchNamespace}
+	}
+	ret := []string{}
+	for _, ns := range strings.Split(*namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			ret = append(ret, ns)
+		}
+	}
+	return ret
+}
+
+func validateNamespacesFlags() error {
+	if *namespaces == "" {
+		return nil
+	}
+	if *watchNamespace != "" {
+		return fmt.Errorf("flags `namespaces` and `watch-namespace` cannot be used together")
+	}
+	if len(listedNamespaces()) == 0 {
+		return fmt.Errorf("invalid value `%s` of flag `namespaces`", *namespaces)
+	}
+	return nil
+}
+
+// listNamespacedHpas lists the HPAs of each listed namespace.
+func listNamespacedHpas() ([]as_v2.HorizontalPodAutoscaler, error) {
+	ret := []as_v2.HorizontalPodAutoscaler{}
+	for _, ns := range listedNamespaces() {
+		hpa, err := listHpasWithFallback(ns)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, hpa...)
+	}
+	return ret, nil
+}
+
+// hpaInformer keeps the HPAs of a namespace up to date with a watch.
+type hpaInformer struct {
+	namespace string
+
+	mu     sync.RWMutex
+	items  map[types.UID]as_v2.HorizontalPodAutoscaler
+	synced bool
+	err    error
+	// lastUpdate is when the HPAs were last listed or an event was received.
+	lastUpdate time.Time
+	watcher    watch.Interface
+	relist     bool
+	// v1 is set when the namespace is only served with autoscaling/v1.
+	v1 bool
+}
+
+var hpaInformers []*hpaInformer
+
+// startHpaInformers lists the namespaces of flag `namespaces` and then
+// keeps watching them.
+func startHpaInformers() {
+	if *namespaces == "" || !featureEnabled(featureHPAInformers) {
+		return
+	}
+	for _, ns := range listedNamespaces() {
+		i := &hpaInformer{namespace: ns, items: map[types.UID]as_v2.HorizontalPodAutoscaler{}}
+		hpaInformers = append(hpaInformers, i)
+		rv, err := i.list()
+		if err != nil {
+			log.Errorf("list HPAs in namespace %s: %v", ns, err)
+		}
+		go i.run(rv)
+	}
+	if *watchStaleAfter > 0 {
+		go watchdog()
+	}
+}
+
+// stale tells whether the watch has not delivered an update for
+// watchStaleAfter though there are HPAs, which should be updated by the
+// controller at least on every change of their metrics.
+func (i *hpaInformer) stale(now time.Time) (bool, time.Time) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.watcher != nil && len(i.items) > 0 && now.Sub(i.lastUpdate) > *watchStaleAfter, i.lastUpdate
+}
+
+// restart stops the watch and makes run list the HPAs again.
+func (i *hpaInformer) restart() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.relist = true
+	if i.watcher != nil {
+		i.watcher.Stop()
+		i.watcher = nil
+	}
+}
+
+func watchdog() {
+	for {
+		time.Sleep(*watchStaleAfter / 4)
+		now := time.Now()
+		for _, i := range hpaInformers {
+			if stale, last := i.stale(now); stale {
+				log.Warnf("HPA watch of namespace %s has not delivered an update since %s, restarting it", i.namespace, last.Format(time.RFC3339))
+				watchRestartsTotal.WithLabelValues(i.namespace).Inc()
+				i.restart()
+			}
+		}
+	}
+}
+
+func (i *hpaInformer) list() (string, error) {
+	hpa, rv, v1, err := listHpasWithFallbackRV(i.namespace)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.err = err
+	if err != nil {
+		return "", err
+	}
+	i.items = map[types.UID]as_v2.HorizontalPodAutoscaler{}
+	for _, a := range hpa {
+		i.items[a.ObjectMeta.UID] = a
+	}
+	i.v1 = v1
+	i.synced = true
+	i.lastUpdate = time.Now()
+	return rv, nil
+}
+
+// watch watches the HPAs with the API version they were last listed with.
+func (i *hpaInformer) watch(rv string) (watch.Interface, error) {
+	i.mu.RLock()
+	v1 := i.v1
+	i.mu.RUnlock()
+	opts := meta_v1.ListOptions{ResourceVersion: rv}
+	if v1 {
+		return kubeClient.AutoscalingV1().HorizontalPodAutoscalers(i.namespace).Watch(opts)
+	}
+	return kubeClient.AutoscalingV2beta1().HorizontalPodAutoscalers(i.namespace).Watch(opts)
+}
+
+// watchedHpa returns the HPA of a watch event, false when the watch failed,
+// e.g. resourceVersion too old.
+func watchedHpa(ev watch.Event) (as_v2.HorizontalPodAutoscaler, bool) {
+	switch a := ev.Object.(type) {
+	case *as_v2.HorizontalPodAutoscaler:
+		return *a, true
+	case *as_v1.HorizontalPodAutoscaler:
+		return convertV1Hpa(*a), true
+	}
+	return as_v2.HorizontalPodAutoscaler{}, false
+}
+
+func (i *hpaInformer) run(rv string) {
+	for {
+		if rv == "" {
+			var err error
+			rv, err = i.list()
+			if err != nil {
+				log.Errorf("list HPAs in namespace %s: %v", i.namespace, err)
+				time.Sleep(time.Duration(*metricsInterval) * time.Second)
+				continue
+			}
+		}
+		w, err := i.watch(rv)
+		if err != nil {
+			log.Errorf("watch HPAs in namespace %s: %v", i.namespace, err)
+			rv = ""
+			time.Sleep(time.Duration(*metricsInterval) * time.Second)
+			continue
+		}
+		i.mu.Lock()
+		i.watcher = w
+		i.mu.Unlock()
+		for ev := range w.ResultChan() {
+			a, ok := watchedHpa(ev)
+			if !ok {
+				rv = ""
+				break
+			}
+			rv = a.ObjectMeta.ResourceVersion
+			i.mu.Lock()
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				i.items[a.ObjectMeta.UID] = a
+			case watch.Deleted:
+				delete(i.items, a.ObjectMeta.UID)
+			}
+			i.lastUpdate = time.Now()
+			i.mu.Unlock()
+		}
+		w.Stop()
+		i.mu.Lock()
+		if i.relist {
+			rv = ""
+			i.relist = false
+		}
+		i.watcher = nil
+		i.mu.Unlock()
+	}
+}
+
+// informerHpaList returns the HPAs of the namespaces listed so far, sorted
+// like the API server lists them. Namespaces that could not be listed are
+// logged and left out, so that one of them does not hide the others.
+func informerHpaList() ([]as_v2.HorizontalPodAutoscaler, error) {
+	ret := []as_v2.HorizontalPodAutoscaler{}
+	synced := 0
+	for _, i := range hpaInformers {
+		i.mu.RLock()
+		if i.synced {
+			synced++
+			for _, a := range i.items {
+				ret = append(ret, a)
+			}
+		}
+		if i.err != nil {
+			log.Errorf("HPAs in namespace %s may be stale: %v", i.namespace, i.err)
+		}
+		i.mu.RUnlock()
+	}
+	if synced == 0 {
+		return nil, fmt.Errorf("no namespace of flag `namespaces` has been listed yet")
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		if ret[a].ObjectMeta.Namespace != ret[b].ObjectMeta.Namespace {
+			return ret[a].ObjectMeta.Namespace < ret[b].ObjectMeta.Namespace
+		}
+		return ret[a].ObjectMeta.Name < ret[b].ObjectMeta.Name
+	})
+	return ret, nil
+}
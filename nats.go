@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var natsURL = newSecret("natsURL", "nats://[user:password@]host:4222 URL of a NATS server to publish condition changes and scale events to, tls:// to require TLS. Nothing is published when empty.")
+var natsSubject = flag.String("natsSubject", defaultNatsSubject, "NATS subject events are published to.")
+var natsJetStream = flag.Bool("natsJetStream", false, "Wait for the JetStream acknowledgement of every event. A stream must capture natsSubject.")
+var natsTLS = newClientTLS("nats", "NATS")
+
+const natsTimeout = 10 * time.Second
+
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// natsConn is a minimal client of the NATS text protocol, which publishes
+// and optionally receives JetStream acknowledgements on an inbox.
+type natsConn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	mu    sync.Mutex
+	inbox string
+	seq   int
+	// acks are the channels of the publishes waiting for an acknowledgement,
+	// by reply subject
+	acks map[string]chan []byte
+	done chan struct{}
+	err  error
+}
+
+func dialNATS(rawurl string) (*natsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported scheme `%s` of natsURL, must be either `nats` or `tls`", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, natsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{conn: conn, r: bufio.NewReader(conn), acks: map[string]chan []byte{}, done: make(chan struct{})}
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+	if err := c.handshake(u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.conn.SetDeadline(time.Time{})
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *natsConn) readLine() (string, error) {
+	l, err := c.r.ReadString('\n')
+	return strings.TrimRight(l, "\r\n"), err
+}
+
+func (c *natsConn) handshake(u *url.URL) error {
+	l, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(l, "INFO ") {
+		return fmt.Errorf("unexpected greeting from NATS server: %q", l)
+	}
+	info := natsInfo{}
+	if err := json.Unmarshal([]byte(l[5:]), &info); err != nil {
+		return err
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		conf, err := natsTLS.Config()
+		if err != nil {
+			return err
+		}
+		conf.ServerName = u.Hostname()
+		tc := tls.Client(c.conn, conf)
+		if err := tc.Handshake(); err != nil {
+			return err
+		}
+		c.conn, c.r = tc, bufio.NewReader(tc)
+	}
+	connect := natsConnect{Name: "hpa-exporter", Lang: "go", Version: "1", Protocol: 1}
+	if u.User != nil {
+		if p, ok := u.User.Password(); ok {
+			connect.User, connect.Pass = u.User.Username(), p
+		} else {
+			connect.AuthToken = u.User.Username()
+		}
+	}
+	b, _ := json.Marshal(connect)
+	cmd := "CONNECT " + string(b) + "\r\n"
+	if *natsJetStream {
+		c.inbox = fmt.Sprintf("_INBOX.hpa-exporter.%d", time.Now().UnixNano())
+		cmd += "SUB " + c.inbox + ".* 1\r\n"
+	}
+	if _, err := c.conn.Write([]byte(cmd + "PING\r\n")); err != nil {
+		return err
+	}
+	for {
+		l, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case l == "PONG":
+			return nil
+		case l == "PING":
+			if _, err := c.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(l, "-ERR"):
+			return errors.New("nats: " + strings.TrimSpace(l[4:]))
+		}
+	}
+}
+
+// readLoop answers the pings of the server and passes acknowledgements on.
+func (c *natsConn) readLoop() {
+	defer close(c.done)
+	for {
+		l, err := c.readLine()
+		if err != nil {
+			c.err = err
+			return
+		}
+		switch {
+		case l == "PING":
+			c.mu.Lock()
+			_, err = c.conn.Write([]byte("PONG\r\n"))
+			c.mu.Unlock()
+		case strings.HasPrefix(l, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			f := strings.Fields(l)
+			var n int
+			if len(f) < 4 {
+				err = fmt.Errorf("nats: invalid message %q", l)
+				break
+			}
+			if n, err = strconv.Atoi(f[len(f)-1]); err != nil || n < 0 {
+				err = fmt.Errorf("nats: invalid message %q", l)
+				break
+			}
+			b := make([]byte, n+2)
+			if _, err = io.ReadFull(c.r, b); err == nil {
+				c.mu.Lock()
+				ack, ok := c.acks[f[1]]
+				delete(c.acks, f[1])
+				c.mu.Unlock()
+				if ok {
+					// buffered, a late acknowledgement finds no channel
+					ack <- b[:n]
+				}
+			}
+		case strings.HasPrefix(l, "-ERR"):
+			err = errors.New("nats: " + strings.TrimSpace(l[4:]))
+		}
+		if err != nil {
+			c.err = err
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+func (c *natsConn) Publish(subject string, payload []byte) error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	c.mu.Lock()
+	reply := ""
+	var acked chan []byte
+	if c.inbox != "" {
+		c.seq++
+		inbox := c.inbox + "." + strconv.Itoa(c.seq)
+		acked = make(chan []byte, 1)
+		c.acks[inbox] = acked
+		defer func() {
+			c.mu.Lock()
+			delete(c.acks, inbox)
+			c.mu.Unlock()
+		}()
+		reply = inbox + " "
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+	_, err := fmt.Fprintf(c.conn, "PUB %s %s%d\r\n%s\r\n", subject, reply, len(payload), payload)
+	c.mu.Unlock()
+	if err != nil || c.inbox == "" {
+		return err
+	}
+	select {
+	case b := <-acked:
+		ack := struct {
+			Error *struct {
+				Description string `json:"description"`
+			} `json:"error"`
+		}{}
+		if err := json.Unmarshal(b, &ack); err != nil {
+			return err
+		}
+		if ack.Error != nil {
+			return errors.New("jetstream: " + ack.Error.Description)
+		}
+		return nil
+	case <-c.done:
+		return c.err
+	case <-time.After(natsTimeout):
+		return errors.New("jetstream: no acknowledgement")
+	}
+}
+
+func (c *natsConn) Close() error {
+	return c.conn.Close()
+}
+
+type natsSink struct {
+	conn    *natsConn
+	tracker changeTracker
+}
+
+func (s *natsSink) Name() string {
+	return "nats"
+}
+
+// Write publishes the changes of the HPAs. Those not published are kept for
+// the next Write.
+func (s *natsSink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	changes := s.tracker.changes(hpa, now)
+	for i, e := range changes {
+		if s.conn == nil {
+			c, err := dialNATS(natsURL.Get())
+			if err != nil {
+				s.tracker.sent(i)
+				return err
+			}
+			s.conn = c
+		}
+		b, _ := json.Marshal(e)
+		if err := s.conn.Publish(*natsSubject, b); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			s.tracker.sent(i)
+			return err
+		}
+	}
+	s.tracker.sent(len(changes))
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// natsServer accepts one connection, greets it like a NATS server and
+// answers its first PING, then hands the connection to serve.
+func natsServer(t *testing.T, serve func(r *bufio.Reader, conn net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.TrimSpace(line) == "PING" {
+				break
+			}
+		}
+		conn.Write([]byte("PONG\r\n"))
+		serve(r, conn)
+	}()
+	return "nats://" + l.Addr().String()
+}
+
+// readPub reads a PUB and its payload and returns the reply subject.
+func readPub(t *testing.T, r *bufio.Reader) string {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Error(err)
+	}
+	f := strings.Fields(line)
+	if len(f) != 4 || f[0] != "PUB" {
+		t.Errorf("unexpected publish %q", line)
+		return ""
+	}
+	return f[2]
+}
+
+func natsMsg(subject, payload string) string {
+	return "MSG " + subject + " 1 " + strconv.Itoa(len(payload)) + "\r\n" + payload + "\r\n"
+}
+
+func TestNATSPublish(t *testing.T) {
+	url := natsServer(t, func(r *bufio.Reader, conn net.Conn) {
+		line, _ := r.ReadString('\n')
+		if line != "PUB hpa.events 2\r\n" {
+			t.Errorf("unexpected publish %q", line)
+		}
+		if payload, _ := r.ReadString('\n'); payload != "{}\r\n" {
+			t.Errorf("unexpected payload %q", payload)
+		}
+	})
+	c, err := dialNATS(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Publish("hpa.events", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNATSJetStreamAck(t *testing.T) {
+	defer func(v bool) { *natsJetStream = v }(*natsJetStream)
+	*natsJetStream = true
+	tests := []struct {
+		name    string
+		acks    []string
+		wantErr string
+	}{
+		{"ack", []string{`{"stream":"hpa","seq":1}`}, ""},
+		{"error", []string{`{"error":{"description":"no stream"}}`}, "jetstream: no stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := natsServer(t, func(r *bufio.Reader, conn net.Conn) {
+				reply := readPub(t, r)
+				// a late acknowledgement of an earlier publish must not
+				// confirm this one
+				stale := reply[:strings.LastIndex(reply, ".")] + ".0"
+				conn.Write([]byte(natsMsg(stale, `{"error":{"description":"stale ack"}}`)))
+				for _, a := range tt.acks {
+					conn.Write([]byte(natsMsg(reply, a)))
+				}
+				r.ReadString('\n')
+			})
+			c, err := dialNATS(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			err = c.Publish("hpa.events", []byte("{}"))
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNATSInvalidURL(t *testing.T) {
+	if _, err := dialNATS("http://localhost:4222"); err == nil || !strings.Contains(err.Error(), "unsupported scheme") {
+		t.Fatalf("got %v, want an unsupported scheme error", err)
+	}
+}
+
+func TestNATSInvalidGreeting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("HELLO\r\n"))
+		conn.Close()
+	}()
+	if _, err := dialNATS("nats://" + l.Addr().String()); err == nil || !strings.Contains(err.Error(), "unexpected greeting") {
+		t.Fatalf("got %v, want an unexpected greeting error", err)
+	}
+}
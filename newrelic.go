@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var newRelicLicenseKey = newSecret("newRelicLicenseKey", "New Relic license key to push the HPA metrics to the Metric API with. Nothing is pushed when empty.")
+var newRelicRegion = flag.String("newRelicRegion", defaultNewRelicRegion, "Region of the New Relic account, either `us` or `eu`.")
+var newRelicAttributes = flag.String("newRelicAttributes", "", "Comma separated key=value attributes common to every pushed metric, e.g. cluster=main.")
+
+var newRelicMetricURLs = map[string]string{
+	"us": "https://metric-api.newrelic.com/metric/v1",
+	"eu": "https://metric-api.eu.newrelic.com/metric/v1",
+}
+
+// newRelicBatchSize keeps the payloads below the 1MB limit of the API.
+const newRelicBatchSize = 2000
+
+type newRelicMetric struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Value      float64           `json:"value"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+type newRelicPayload struct {
+	Common struct {
+		Timestamp  int64             `json:"timestamp"`
+		Attributes map[string]string `json:"attributes,omitempty"`
+	} `json:"common"`
+	Metrics []newRelicMetric `json:"metrics"`
+}
+
+func validateNewRelicFlags() error {
+	if _, ok := newRelicMetricURLs[*newRelicRegion]; !ok {
+		return fmt.Errorf("invalid value `%s` of flag `newRelicRegion`, must be either `us` or `eu`", *newRelicRegion)
+	}
+	return nil
+}
+
+type newRelicSink struct {
+	client *http.Client
+}
+
+func newNewRelicSink() *newRelicSink {
+	return &newRelicSink{client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *newRelicSink) Name() string {
+	return "newrelic"
+}
+
+func (s *newRelicSink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	samples, err := hpaSamples()
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Api-Key": {newRelicLicenseKey.Get()}}
+	for len(samples) > 0 {
+		n := len(samples)
+		if n > newRelicBatchSize {
+			n = newRelicBatchSize
+		}
+		p := newRelicPayload{}
+		p.Common.Timestamp = now.UnixNano() / int64(time.Millisecond)
+		p.Common.Attributes = parseKeyValues(*newRelicAttributes)
+		for _, m := range samples[:n] {
+			p.Metrics = append(p.Metrics, newRelicMetric{
+				Name:       m.Name,
+				Type:       "gauge",
+				Value:      m.Value,
+				Attributes: m.Labels,
+			})
+		}
+		if err := postJSON(s.client, newRelicMetricURLs[*newRelicRegion], header, []newRelicPayload{p}); err != nil {
+			return err
+		}
+		samples = samples[n:]
+	}
+	return nil
+}
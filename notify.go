@@ -0,0 +1,742 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/yaml.v2"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var notifyConfigFile = flag.String("notifyConfig", "", "YAML file of the receivers to notify of findings, e.g. HPAs stuck at max replicas. Nothing is notified when empty.")
+var notifyInterval = flag.Duration("notifyInterval", defaultNotifyInterval, "Interval to evaluate findings and notify the receivers.")
+var notifyRepeatInterval = flag.Duration("notifyRepeatInterval", defaultNotifyRepeatInterval, "Interval to notify a receiver again of a finding that is still active. Receivers can override it with repeatInterval.")
+
+// finding is a problem of an HPA found by a detector.
+type finding struct {
+	Rule      string            `json:"rule"`
+	Severity  string            `json:"severity"`
+	Status    string            `json:"status"`
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	UID       string            `json:"uid"`
+	Summary   string            `json:"summary"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	StartsAt  time.Time         `json:"startsAt"`
+
+	HPA as_v2.HorizontalPodAutoscaler `json:"-"`
+	// Receivers are the names of the receivers to notify, all when nil.
+	Receivers []string `json:"-"`
+}
+
+const (
+	findingFiring   = "firing"
+	findingResolved = "resolved"
+)
+
+func (f finding) Key() string {
+	return f.Rule + "/" + f.Namespace + "/" + f.Name
+}
+
+// CurrentMetrics and TargetMetrics are the metric values of the HPA by
+// kind/name/metric name, e.g. Resource/cpu/-, for templates.
+func (f finding) CurrentMetrics() map[string]float64 {
+	return metricsObject(statusMetrics(f.HPA))
+}
+
+func (f finding) TargetMetrics() map[string]float64 {
+	return metricsObject(specMetrics(f.HPA))
+}
+
+// DemandReplicas is the replicas the metrics ask for without the min/max
+// bounds.
+func (f finding) DemandReplicas() float64 {
+	return demandReplicas(f.HPA)
+}
+
+// detection reports the HPAs for which the gauge of a detector is 1 as
+// findings.
+type detection struct {
+	Rule     string
+	Metric   string
+	Severity string
+	Summary  string
+}
+
+var detections = []detection{
+	{"Flapping", "hpa_flapping", "warning", "desired replicas keep changing direction"},
+	{"StuckAtMax", "hpa_stuck_at_max_replicas", "critical", "at max replicas with a metric above its target"},
+	{"Idle", "hpa_idle", "info", "at min replicas with all metrics far below their targets"},
+	{"EffectivelyDisabled", "hpa_effectively_disabled", "warning", "cannot change replicas"},
+	{"TargetReplicasConflict", "hpa_target_replicas_conflict", "warning", "spec.replicas of the scale target differs from the desired replicas"},
+	{"DuplicateTarget", "hpa_duplicate_target", "warning", "another HPA has the same scale target"},
+	{"ControllerConflict", "hpa_controller_conflict", "warning", "the scale target is also managed by another autoscaling controller"},
+	{"MetricFetchFailed", "hpa_metric_fetch_failed", "warning", "the HPA controller fails to get metrics"},
+}
+
+// receiver sends notifications of findings, e.g. to a chat or an incident
+// management service. A finding is notified when it starts firing, again
+// every repeat interval, and once more with status resolved when it is gone.
+type receiver interface {
+	Notify(fs []finding) error
+}
+
+// bufferingReceiver only buffers the findings it is notified of, e.g. for a
+// digest, and sends them when flushed. Findings count as sent once a flush
+// returned them.
+type bufferingReceiver interface {
+	receiver
+	// Flush sends the buffered findings when they are due, or anyway with
+	// force, and returns those it sent.
+	Flush(now time.Time, force bool) ([]finding, error)
+	// SendNow sends fs at once, without buffering them or flushing the
+	// buffered findings.
+	SendNow(fs []finding) error
+}
+
+type receiverConfig struct {
+	Name           string              `yaml:"name"`
+	RepeatInterval *time.Duration      `yaml:"repeatInterval"`
+	Webhook        *webhookConfig      `yaml:"webhook"`
+	Alertmanager   *alertmanagerConfig `yaml:"alertmanager"`
+	Teams          *teamsConfig        `yaml:"teams"`
+	Opsgenie       *opsgenieConfig     `yaml:"opsgenie"`
+	Discord        *discordConfig      `yaml:"discord"`
+	Email          *emailConfig        `yaml:"email"`
+	Templates      messageTemplates    `yaml:"templates"`
+}
+
+type notifyConfig struct {
+	Receivers []receiverConfig `yaml:"receivers"`
+	Rules     []*notifyRule    `yaml:"rules"`
+	Silences  []*silence       `yaml:"silences"`
+	Routing   routing          `yaml:",inline"`
+}
+
+type namedReceiver struct {
+	name   string
+	repeat time.Duration
+	receiver
+}
+
+var (
+	receiversMu   sync.RWMutex
+	receivers     []namedReceiver
+	notifyRules   []*notifyRule
+	notifyRouting = &routing{}
+)
+
+func newReceiver(c receiverConfig) (receiver, error) {
+	if (c.Webhook != nil || c.Email != nil) && !c.Templates.empty() {
+		// they template the whole request or mail instead
+		return nil, fmt.Errorf("templates are not used by webhook and email receivers, set their own templates")
+	}
+	t, err := c.Templates.compile()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c.Webhook != nil:
+		return newWebhookReceiver(c.Webhook)
+	case c.Alertmanager != nil:
+		return newAlertmanagerReceiver(c.Alertmanager, t)
+	case c.Teams != nil:
+		return newTeamsReceiver(c.Teams, t)
+	case c.Opsgenie != nil:
+		return newOpsgenieReceiver(c.Opsgenie, t)
+	case c.Discord != nil:
+		return newDiscordReceiver(c.Discord, t)
+	case c.Email != nil:
+		return newEmailReceiver(c.Email)
+	}
+	return nil, fmt.Errorf("receiver `%s` has no type", c.Name)
+}
+
+// notifySetup is a parsed notify config, not yet in use.
+type notifySetup struct {
+	receivers []namedReceiver
+	rules     []*notifyRule
+	silences  []*silence
+	routing   *routing
+}
+
+func loadNotifyConfig(path string) error {
+	n, err := parseNotifyConfig(path)
+	if err != nil {
+		return err
+	}
+	// the replaced receivers are not flushed anymore
+	flushReceivers(setNotifyConfig(n), time.Now(), true)
+	return nil
+}
+
+func parseNotifyConfig(path string) (*notifySetup, error) {
+	rs := []namedReceiver{}
+	rules := []*notifyRule{}
+	silences := []*silence{}
+	rt := &routing{}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c := notifyConfig{}
+		if err := yaml.UnmarshalStrict(b, &c); err != nil {
+			return nil, fmt.Errorf("parse notify config %s: %v", path, err)
+		}
+		names := map[string]bool{}
+		for _, rc := range c.Receivers {
+			if rc.Name == "" || names[rc.Name] {
+				return nil, fmt.Errorf("receiver names must be unique and not empty, got `%s`", rc.Name)
+			}
+			names[rc.Name] = true
+			r, err := newReceiver(rc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid receiver `%s`: %v", rc.Name, err)
+			}
+			repeat := *notifyRepeatInterval
+			if rc.Alertmanager != nil {
+				// Alertmanager resolves alerts that are not sent again
+				repeat = 0
+			}
+			if rc.RepeatInterval != nil {
+				repeat = *rc.RepeatInterval
+			}
+			rs = append(rs, namedReceiver{rc.Name, repeat, r})
+		}
+		ruleNames := map[string]bool{}
+		for _, r := range c.Rules {
+			if err := r.compile(names); err != nil {
+				return nil, err
+			}
+			if ruleNames[r.Name] {
+				return nil, fmt.Errorf("duplicate rule `%s`", r.Name)
+			}
+			ruleNames[r.Name] = true
+		}
+		rules = c.Rules
+		for _, r := range thresholdRules() {
+			if ruleNames[r.Name] {
+				// a rule of the same name in the config takes precedence
+				continue
+			}
+			if err := r.compile(names); err != nil {
+				return nil, err
+			}
+			r.threshold = true
+			rules = append(rules, r)
+		}
+		for i, s := range c.Silences {
+			if s.ID == "" {
+				s.ID = fmt.Sprintf("config-%d", i)
+			}
+			if err := s.compile(); err != nil {
+				return nil, fmt.Errorf("invalid silence `%s`: %v", s.ID, err)
+			}
+		}
+		silences = c.Silences
+		if err := c.Routing.compile(names); err != nil {
+			return nil, err
+		}
+		rt = &c.Routing
+	}
+	return &notifySetup{rs, rules, silences, rt}, nil
+}
+
+// setNotifyConfig puts n in use and returns the replaced receivers.
+func setNotifyConfig(n *notifySetup) []namedReceiver {
+	setConfigSilences(n.silences)
+	receiversMu.Lock()
+	old := receivers
+	receivers = n.receivers
+	notifyRules = n.rules
+	notifyRouting = n.routing
+	receiversMu.Unlock()
+	return old
+}
+
+var notifyTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+	"facts": findingFacts,
+}
+
+// notifyData is what notification templates are executed with: the fields
+// of the first finding, and all findings in Findings.
+type notifyData struct {
+	finding
+	Findings []finding
+}
+
+func newNotifyData(fs []finding) notifyData {
+	return notifyData{finding: fs[0], Findings: fs}
+}
+
+func parseNotifyTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(notifyTemplateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// messageTemplates override the title, body and fields of the messages of
+// chat and incident receivers.
+type messageTemplates struct {
+	Title  string          `yaml:"title"`
+	Body   string          `yaml:"body"`
+	Fields []templateField `yaml:"fields"`
+}
+
+func (m messageTemplates) empty() bool {
+	return m.Title == "" && m.Body == "" && len(m.Fields) == 0
+}
+
+type templateField struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type messageTemplate struct {
+	title, body *template.Template
+	fields      []*template.Template
+	names       []string
+}
+
+func (m messageTemplates) compile() (*messageTemplate, error) {
+	t := &messageTemplate{}
+	var err error
+	if m.Title != "" {
+		if t.title, err = parseNotifyTemplate("title", m.Title); err != nil {
+			return nil, err
+		}
+	}
+	if m.Body != "" {
+		if t.body, err = parseNotifyTemplate("body", m.Body); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range m.Fields {
+		ft, err := parseNotifyTemplate("field "+f.Name, f.Value)
+		if err != nil {
+			return nil, err
+		}
+		t.names = append(t.names, f.Name)
+		t.fields = append(t.fields, ft)
+	}
+	return t, nil
+}
+
+// message renders the title, body and fields of the message of a finding,
+// by default `[status] rule`, the summary and findingFacts.
+func (t *messageTemplate) message(f finding) (title, body string, fields [][2]string, err error) {
+	data := newNotifyData([]finding{f})
+	title = fmt.Sprintf("[%s] %s", f.Status, f.Rule)
+	if t.title != nil {
+		if title, err = executeTemplate(t.title, data); err != nil {
+			return
+		}
+	}
+	body = f.Summary
+	if t.body != nil {
+		if body, err = executeTemplate(t.body, data); err != nil {
+			return
+		}
+	}
+	if len(t.fields) == 0 {
+		return title, body, findingFacts(f), nil
+	}
+	for i, ft := range t.fields {
+		v, e := executeTemplate(ft, data)
+		if e != nil {
+			return "", "", nil, e
+		}
+		fields = append(fields, [2]string{t.names[i], v})
+	}
+	return
+}
+
+func executeTemplate(t *template.Template, data interface{}) (string, error) {
+	b := &bytes.Buffer{}
+	err := t.Execute(b, data)
+	return b.String(), err
+}
+
+// collectedFindings are the findings of the detectors and the HPAs they
+// were found in as of the end of the last collection, so an evaluation
+// never sees the gauges half refilled.
+var (
+	collectedFindingsMu  sync.RWMutex
+	collectedFindings    []finding
+	collectedFindingsHpa []as_v2.HorizontalPodAutoscaler
+)
+
+// snapshotFindings is called at the end of collectMetrics while holding
+// collectMu.
+func snapshotFindings(hpa []as_v2.HorizontalPodAutoscaler) error {
+	fs, err := currentFindings(hpa)
+	if err != nil {
+		return err
+	}
+	collectedFindingsMu.Lock()
+	collectedFindings = fs
+	collectedFindingsHpa = hpa
+	collectedFindingsMu.Unlock()
+	return nil
+}
+
+// lastFindings returns a copy of the findings of the last collection. ok is
+// false before the first collection.
+func lastFindings() (fs []finding, hpa []as_v2.HorizontalPodAutoscaler, ok bool) {
+	collectedFindingsMu.RLock()
+	defer collectedFindingsMu.RUnlock()
+	if collectedFindings == nil {
+		return nil, nil, false
+	}
+	return append([]finding{}, collectedFindings...), collectedFindingsHpa, true
+}
+
+// currentFindings reads the findings from the gauges of the detectors.
+func currentFindings(hpa []as_v2.HorizontalPodAutoscaler) ([]finding, error) {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	byMetric := map[string]detection{}
+	for _, d := range detections {
+		byMetric[d.Metric] = d
+	}
+	byKey := map[string]as_v2.HorizontalPodAutoscaler{}
+	for _, a := range hpa {
+		byKey[hpaKey(a)] = a
+	}
+	ret := []finding{}
+	for _, mf := range mfs {
+		d, ok := byMetric[mf.GetName()]
+		if !ok {
+			continue
+		}
+		for _, m := range mf.Metric {
+			if m.GetGauge().GetValue() != 1 {
+				continue
+			}
+			a, ok := byKey[labelValue(m, "hpa_namespace")+"/"+labelValue(m, "hpa_name")]
+			if !ok {
+				continue
+			}
+			f := finding{
+				Rule:      d.Rule,
+				Severity:  d.Severity,
+				Status:    findingFiring,
+				Namespace: a.ObjectMeta.Namespace,
+				Name:      a.ObjectMeta.Name,
+				UID:       string(a.ObjectMeta.UID),
+				Summary:   fmt.Sprintf("HPA %s: %s", hpaKey(a), d.Summary),
+				Labels:    map[string]string{},
+				HPA:       a,
+			}
+			for _, l := range m.Label {
+				f.Labels[l.GetName()] = l.GetValue()
+			}
+			ret = append(ret, f)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Key() < ret[j].Key() })
+	return ret, nil
+}
+
+// findingsSince remembers when the notifier first saw each finding.
+var findingsSince = map[string]time.Time{}
+
+// receiverFindings returns the findings to notify the receiver of.
+func receiverFindings(name string, fs []finding) []finding {
+	ret := []finding{}
+	for _, f := range fs {
+		if f.Receivers == nil {
+			ret = append(ret, f)
+			continue
+		}
+		for _, r := range f.Receivers {
+			if r == name {
+				ret = append(ret, f)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// notifyState is what a receiver was notified of a finding. buffered is
+// set while a bufferingReceiver holds the finding unsent.
+type notifyState struct {
+	finding  finding
+	lastSent time.Time
+	buffered bool
+}
+
+// notified holds the active findings of each receiver by key.
+var (
+	notifiedMu sync.Mutex
+	notified   = map[string]map[string]*notifyState{}
+)
+
+// dueFindings returns the findings of fs the receiver is due to be notified
+// of, and the findings it was notified of that are gone, as resolved.
+// Silenced findings are kept active without being notified.
+func dueFindings(r namedReceiver, fs []finding, silences []*silence, now time.Time) []finding {
+	prev := notified[r.name]
+	cur := map[string]*notifyState{}
+	ret := []finding{}
+	for _, f := range fs {
+		st, ok := prev[f.Key()]
+		if !ok {
+			st = &notifyState{}
+		}
+		st.finding = f
+		cur[f.Key()] = st
+		if silenced(silences, f) || st.buffered {
+			continue
+		}
+		if st.lastSent.IsZero() || now.Sub(st.lastSent) >= r.repeat {
+			ret = append(ret, f)
+		}
+	}
+	for k, st := range prev {
+		if _, ok := cur[k]; ok || (st.lastSent.IsZero() && !st.buffered) || silenced(silences, st.finding) {
+			continue
+		}
+		f := st.finding
+		f.Status = findingResolved
+		ret = append(ret, f)
+	}
+	notified[r.name] = cur
+	return ret
+}
+
+func notify(fs []finding, now time.Time) {
+	receiversMu.RLock()
+	rs := receivers
+	receiversMu.RUnlock()
+	silences := activeSilences(now)
+	notifiedMu.Lock()
+	defer notifiedMu.Unlock()
+	names := map[string]bool{}
+	for _, r := range rs {
+		names[r.name] = true
+		due := dueFindings(r, receiverFindings(r.name, fs), silences, now)
+		if len(due) == 0 {
+			continue
+		}
+		if err := r.Notify(due); err != nil {
+			log.Errorf("notify %s: %v", r.name, err)
+			notificationsTotal.WithLabelValues(r.name, "failure").Inc()
+			// resolve again at the next evaluation
+			for _, f := range due {
+				if f.Status == findingResolved {
+					f.Status = findingFiring
+					notified[r.name][f.Key()] = &notifyState{finding: f, lastSent: now}
+				}
+			}
+			continue
+		}
+		if _, ok := r.receiver.(bufferingReceiver); ok {
+			for _, f := range due {
+				if st, ok := notified[r.name][f.Key()]; ok && f.Status == findingFiring {
+					st.buffered = true
+				}
+			}
+			continue
+		}
+		notificationsTotal.WithLabelValues(r.name, "success").Inc()
+		markSent(r.name, due, now)
+	}
+	for name := range notified {
+		if !names[name] {
+			delete(notified, name)
+		}
+	}
+}
+
+// markSent records that the receiver was sent the findings. It is called
+// while holding notifiedMu.
+func markSent(name string, fs []finding, now time.Time) {
+	for _, f := range fs {
+		if st, ok := notified[name][f.Key()]; ok && f.Status == findingFiring {
+			st.lastSent = now
+			st.buffered = false
+		}
+	}
+}
+
+// flushReceivers sends what the buffering receivers of rs hold, when it is
+// due or anyway with force, e.g. on shutdown.
+func flushReceivers(rs []namedReceiver, now time.Time, force bool) {
+	for _, r := range rs {
+		b, ok := r.receiver.(bufferingReceiver)
+		if !ok {
+			continue
+		}
+		fs, err := b.Flush(now, force)
+		if err != nil {
+			log.Errorf("notify %s: %v", r.name, err)
+			notificationsTotal.WithLabelValues(r.name, "failure").Inc()
+			if force {
+				// the buffer may be gone with the receiver, notify again
+				notifiedMu.Lock()
+				for _, st := range notified[r.name] {
+					st.buffered = false
+				}
+				notifiedMu.Unlock()
+			}
+			continue
+		}
+		if len(fs) == 0 {
+			continue
+		}
+		notificationsTotal.WithLabelValues(r.name, "success").Inc()
+		notifiedMu.Lock()
+		markSent(r.name, fs, now)
+		notifiedMu.Unlock()
+	}
+}
+
+func currentReceivers() []namedReceiver {
+	receiversMu.RLock()
+	defer receiversMu.RUnlock()
+	return receivers
+}
+
+// flushAllReceivers is registered with onShutdown.
+func flushAllReceivers() {
+	flushReceivers(currentReceivers(), time.Now(), true)
+}
+
+// evaluateFindings notifies the findings of the rules, or of all
+// detections when there are no rules.
+func evaluateFindings(now time.Time) error {
+	fs, hpa, ok := lastFindings()
+	if !ok {
+		return nil
+	}
+	var err error
+	receiversMu.RLock()
+	rules := notifyRules
+	rt := notifyRouting
+	names := []string{}
+	for _, r := range receivers {
+		names = append(names, r.name)
+	}
+	receiversMu.RUnlock()
+	if len(rules) > 0 {
+		ruled := evaluateRules(rules, hpa, fs, now)
+		if onlyThresholdRules(rules) {
+			// without rules in the config, detections are still notified
+			fs = append(fs, ruled...)
+		} else {
+			fs = ruled
+		}
+	}
+	if fs, err = rt.route(fs, names); err != nil {
+		return err
+	}
+	since := map[string]time.Time{}
+	for i, f := range fs {
+		s, ok := findingsSince[f.Key()]
+		if !ok {
+			s = now
+		}
+		if !f.StartsAt.IsZero() {
+			s = f.StartsAt
+		}
+		since[f.Key()] = s
+		fs[i].StartsAt = s
+	}
+	findingsSince = since
+	notify(fs, now)
+	return nil
+}
+
+// runNotifier evaluates the findings and flushes the buffering receivers
+// every notifyInterval, whether or not an evaluation notified them.
+func runNotifier() {
+	for range time.Tick(*notifyInterval) {
+		if err := evaluateFindings(time.Now()); err != nil {
+			log.Errorln("evaluate findings:", err)
+		}
+		flushReceivers(currentReceivers(), time.Now(), false)
+	}
+}
+
+// testFinding is a synthetic finding of the HPA namespace/name, or of the
+// first cached HPA, or of a made up one.
+func testFinding(namespace, name string, now time.Time) finding {
+	a := as_v2.HorizontalPodAutoscaler{}
+	a.ObjectMeta.Namespace, a.ObjectMeta.Name = "default", "hpa-exporter-test"
+	for i, c := range cachedHpaList() {
+		if (namespace == "" && name == "" && i == 0) || (c.ObjectMeta.Namespace == namespace && c.ObjectMeta.Name == name) {
+			a = c
+		}
+	}
+	return finding{
+		Rule:      "Test",
+		Severity:  "info",
+		Status:    findingFiring,
+		Namespace: a.ObjectMeta.Namespace,
+		Name:      a.ObjectMeta.Name,
+		UID:       "hpa-exporter-test",
+		Summary:   fmt.Sprintf("HPA %s: test notification from hpa-exporter, please ignore", hpaKey(a)),
+		Labels:    map[string]string{},
+		StartsAt:  now,
+		HPA:       a,
+	}
+}
+
+// notifyTestHandler sends a test finding through a receiver, bypassing
+// deduplication and silences. A bufferingReceiver sends it on its own, so
+// it is neither delayed nor mixed into the buffered findings.
+func notifyTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("receiver")
+	receiversMu.RLock()
+	var rc *namedReceiver
+	for i := range receivers {
+		if receivers[i].name == name {
+			rc = &receivers[i]
+		}
+	}
+	receiversMu.RUnlock()
+	if rc == nil {
+		http.Error(w, fmt.Sprintf("unknown receiver `%s`", name), http.StatusNotFound)
+		return
+	}
+	f := testFinding(r.URL.Query().Get("namespace"), r.URL.Query().Get("name"), time.Now())
+	if r.URL.Query().Get("status") == findingResolved {
+		f.Status = findingResolved
+	}
+	notify := rc.Notify
+	if b, ok := rc.receiver.(bufferingReceiver); ok {
+		notify = b.SendNow
+	}
+	if err := notify([]finding{f}); err != nil {
+		log.Errorf("notify test %s: %v", name, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Infof("sent test notification to %s", name)
+	fmt.Fprintf(w, "sent test notification of %s to %s\n", hpaKey(f.HPA), name)
+}
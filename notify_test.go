@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReceiverTemplates(t *testing.T) {
+	templates := messageTemplates{Title: "{{ .Rule }}"}
+	for _, c := range []struct {
+		name   string
+		config receiverConfig
+		err    string
+	}{
+		{"webhook", receiverConfig{Name: "hook", Webhook: &webhookConfig{URL: "http://example.com"}, Templates: templates}, "templates are not used"},
+		{"email", receiverConfig{Name: "mail", Email: &emailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}, Templates: templates}, "templates are not used"},
+		{"webhook without templates", receiverConfig{Name: "hook", Webhook: &webhookConfig{URL: "http://example.com"}}, ""},
+		{"teams", receiverConfig{Name: "teams", Teams: &teamsConfig{URL: "http://example.com"}, Templates: templates}, ""},
+		{"invalid template", receiverConfig{Name: "teams", Teams: &teamsConfig{URL: "http://example.com"}, Templates: messageTemplates{Body: "{{ .Rule"}}, "body"},
+	} {
+		_, err := newReceiver(c.config)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: got %v, want an error containing `%s`", c.name, err, c.err)
+		}
+	}
+}
+
+// fakeDigest buffers the findings it is notified of and records those sent.
+type fakeDigest struct {
+	buffered, sent []finding
+}
+
+func (d *fakeDigest) Notify(fs []finding) error {
+	d.buffered = append(d.buffered, fs...)
+	return nil
+}
+
+func (d *fakeDigest) Flush(now time.Time, force bool) ([]finding, error) {
+	d.sent = append(d.sent, d.buffered...)
+	fs := d.buffered
+	d.buffered = nil
+	return fs, nil
+}
+
+func (d *fakeDigest) SendNow(fs []finding) error {
+	d.sent = append(d.sent, fs...)
+	return nil
+}
+
+func TestNotifyTestBufferingReceiver(t *testing.T) {
+	defer func(rs []namedReceiver) { receivers = rs }(receivers)
+	pending := finding{Rule: "StuckAtMax", Namespace: "prod", Name: "web"}
+	d := &fakeDigest{buffered: []finding{pending}}
+	receivers = []namedReceiver{{name: "digest", receiver: d}}
+	w := httptest.NewRecorder()
+	notifyTestHandler(w, httptest.NewRequest(http.MethodPost, "/-/notify/test?receiver=digest", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	if len(d.sent) != 1 || d.sent[0].Rule != "Test" {
+		t.Fatalf("got sent %v, want only the test finding", d.sent)
+	}
+	if len(d.buffered) != 1 || d.buffered[0].Rule != "StuckAtMax" {
+		t.Fatalf("got buffered %v, want the pending finding alone", d.buffered)
+	}
+}
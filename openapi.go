@@ -0,0 +1,252 @@
+package main
+
+import (
+	"net/http"
+)
+
+const openAPIDoc = `{
+  "openapi": "3.0.0",
+  "info": {
+    "title": "HPA Exporter API",
+    "version": "v1"
+  },
+  "paths": {
+    "/api/v1/hpas.csv": {
+      "get": {
+        "summary": "HPA state of the last collection as CSV",
+        "operationId": "getHpasCSV",
+        "responses": {
+          "200": {
+            "description": "One row per HPA.",
+            "content": {
+              "text/csv": {
+                "schema": {
+                  "type": "string",
+                  "description": "Columns: namespace, name, ref_kind, ref_name, min_replicas, max_replicas, current_replicas, desired_replicas, target_metrics, current_metrics, conditions."
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/api/v1/hpas/{namespace}/{name}/history": {
+      "get": {
+        "summary": "Replica and metric history of an HPA",
+        "operationId": "getHpaHistory",
+        "parameters": [
+          {
+            "name": "namespace",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "name",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "window",
+            "in": "query",
+            "required": false,
+            "description": "How far back to return samples, e.g. 6h. Defaults to historyRetention.",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Samples in time order, at most one per minute.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/HistorySample"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid window."
+          },
+          "404": {
+            "description": "No such HPA."
+          }
+        }
+      }
+    },
+    "/api/v1/alert-rules": {
+      "get": {
+        "summary": "Alerting rules for this exporter",
+        "operationId": "getAlertRules",
+        "parameters": [
+          {
+            "name": "format",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string",
+              "enum": ["prometheusrule", "rules"],
+              "default": "prometheusrule"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "A PrometheusRule manifest or a Prometheus rule file.",
+            "content": {
+              "application/x-yaml": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Unknown format."
+          }
+        }
+      }
+    },
+    "/api/v1/dashboards/grafana": {
+      "get": {
+        "summary": "Grafana dashboard for this exporter",
+        "operationId": "getGrafanaDashboard",
+        "responses": {
+          "200": {
+            "description": "Grafana dashboard model.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/-/healthy": {
+      "get": {
+        "summary": "Health check",
+        "operationId": "getHealthy",
+        "parameters": [
+          {
+            "name": "deep",
+            "in": "query",
+            "required": false,
+            "description": "Check connectivity to the Kubernetes API and the logging backend.",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Healthy. The body is the HealthReport when deep=true.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/HealthReport"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "A dependency is failing.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/HealthReport"
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "HealthReport": {
+        "type": "object",
+        "properties": {
+          "status": {
+            "type": "string",
+            "enum": ["ok", "error"]
+          },
+          "dependencies": {
+            "type": "object",
+            "additionalProperties": {
+              "$ref": "#/components/schemas/DependencyStatus"
+            }
+          }
+        }
+      },
+      "HistorySample": {
+        "type": "object",
+        "properties": {
+          "time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "current": {
+            "type": "integer"
+          },
+          "desired": {
+            "type": "integer"
+          },
+          "min": {
+            "type": "integer"
+          },
+          "max": {
+            "type": "integer"
+          },
+          "demand": {
+            "type": "number",
+            "description": "Replicas the metrics ask for without the min/max bounds."
+          },
+          "currentMetrics": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "number"
+            }
+          },
+          "targetMetrics": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "number"
+            }
+          }
+        }
+      },
+      "DependencyStatus": {
+        "type": "object",
+        "properties": {
+          "status": {
+            "type": "string",
+            "enum": ["ok", "error"]
+          },
+          "error": {
+            "type": "string"
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(openAPIDoc))
+}
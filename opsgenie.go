@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// opsgenieConfig creates an Opsgenie alert per finding, aliased by HPA UID
+// and rule so that repeated notifications are deduplicated, and closes it
+// when the finding is resolved.
+type opsgenieConfig struct {
+	APIKey     string            `yaml:"apiKey"`
+	APIKeyFile string            `yaml:"apiKeyFile"`
+	Region     string            `yaml:"region"`
+	Priorities map[string]string `yaml:"priorities"`
+	Tags       []string          `yaml:"tags"`
+	Timeout    time.Duration     `yaml:"timeout"`
+}
+
+var opsgenieAPIs = map[string]string{
+	"us": "https://api.opsgenie.com/v2/alerts",
+	"eu": "https://api.eu.opsgenie.com/v2/alerts",
+}
+
+var defaultOpsgeniePriorities = map[string]string{
+	"critical": "P1",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+type opsgenieReceiver struct {
+	config   *opsgenieConfig
+	client   *http.Client
+	api      string
+	template *messageTemplate
+}
+
+func newOpsgenieReceiver(c *opsgenieConfig, t *messageTemplate) (*opsgenieReceiver, error) {
+	if (c.APIKey == "") == (c.APIKeyFile == "") {
+		return nil, fmt.Errorf("opsgenie needs either apiKey or apiKeyFile")
+	}
+	if c.Region == "" {
+		c.Region = "us"
+	}
+	api, ok := opsgenieAPIs[c.Region]
+	if !ok {
+		return nil, fmt.Errorf("invalid region `%s`, must be either `us` or `eu`", c.Region)
+	}
+	for sev, p := range defaultOpsgeniePriorities {
+		if _, ok := c.Priorities[sev]; !ok {
+			if c.Priorities == nil {
+				c.Priorities = map[string]string{}
+			}
+			c.Priorities[sev] = p
+		}
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &opsgenieReceiver{
+		config:   c,
+		client:   &http.Client{Timeout: timeout},
+		api:      api,
+		template: t,
+	}, nil
+}
+
+func (r *opsgenieReceiver) header() (http.Header, error) {
+	key := r.config.APIKey
+	if r.config.APIKeyFile != "" {
+		b, err := ioutil.ReadFile(r.config.APIKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = strings.TrimSpace(string(b))
+	}
+	return http.Header{"Authorization": {"GenieKey " + key}}, nil
+}
+
+func opsgenieAlias(f finding) string {
+	return f.UID + "/" + f.Rule
+}
+
+func (r *opsgenieReceiver) Notify(fs []finding) error {
+	header, err := r.header()
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		if f.Status == findingResolved {
+			closeURL := r.api + "/" + url.PathEscape(opsgenieAlias(f)) + "/close?identifierType=alias"
+			if err := postJSON(r.client, closeURL, header, map[string]string{"source": "hpa-exporter", "note": "resolved"}); err != nil {
+				return err
+			}
+			continue
+		}
+		priority := r.config.Priorities[f.Severity]
+		if priority == "" {
+			priority = "P3"
+		}
+		title, body, fields, err := r.template.message(f)
+		if err != nil {
+			return err
+		}
+		// the default title only has the status and rule
+		message := f.Summary
+		if r.template.title != nil {
+			message = title
+		}
+		if len(message) > 130 {
+			message = message[:130]
+		}
+		details := map[string]string{
+			"namespace": f.Namespace,
+			"name":      f.Name,
+			"rule":      f.Rule,
+			"severity":  f.Severity,
+		}
+		description := ""
+		if r.template.body != nil {
+			description = body + "\n\n"
+		}
+		for _, kv := range fields {
+			description += kv[0] + ": " + kv[1] + "\n"
+		}
+		err = postJSON(r.client, r.api, header, map[string]interface{}{
+			"message":     message,
+			"alias":       opsgenieAlias(f),
+			"description": description,
+			"priority":    priority,
+			"tags":        append([]string{f.Rule, f.Namespace}, r.config.Tags...),
+			"details":     details,
+			"entity":      hpaKey(f.HPA),
+			"source":      "hpa-exporter",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var otlpLogsEndpoint = flag.String("otlpLogsEndpoint", defaultOTLPLogsEndpoint, "OTLP/HTTP logs endpoint of an OpenTelemetry collector to export condition logs to with -loggingTo=otlp.")
+var otlpHeaders = newSecret("otlpHeaders", "Comma separated key=value headers of the OTLP requests, e.g. authorization=Bearer <token>.")
+var otlpTLS = newClientTLS("otlp", "the OTLP collector")
+var otlpResourceAttributes = flag.String("otlpResourceAttributes", "", "Comma separated key=value resource attributes of the exported logs, e.g. k8s.cluster.name=main. service.name defaults to hpa-exporter.")
+
+// The types below are the OTLP/HTTP JSON encoding of
+// ExportLogsServiceRequest, which collectors accept without the protobuf
+// definitions. 64 bit integers are strings in it.
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// otlpSeverityInfo is SEVERITY_NUMBER_INFO.
+const otlpSeverityInfo = 9
+
+var (
+	otlpClientMu sync.Mutex
+	otlpClient   *http.Client
+)
+
+// otlpHTTPClient returns the client of the exports, created on first use
+// and again after resetOTLPClient, e.g. on reload.
+func otlpHTTPClient() (*http.Client, error) {
+	otlpClientMu.Lock()
+	defer otlpClientMu.Unlock()
+	if otlpClient == nil {
+		c, err := otlpTLS.HTTPClient(30 * time.Second)
+		if err != nil {
+			return nil, err
+		}
+		otlpClient = c
+	}
+	return otlpClient, nil
+}
+
+func resetOTLPClient() {
+	otlpClientMu.Lock()
+	otlpClient = nil
+	otlpClientMu.Unlock()
+}
+
+func otlpAttributes(m map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, otlpAttribute{k, otlpValue{m[k]}})
+	}
+	return ret
+}
+
+// otlpLogs returns a ResourceLogs per HPA, whose resource attributes are
+// those of otlpResourceAttributes and the namespace and name of the HPA,
+// with a record for each line logged for its conditions.
+func otlpLogs(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) otlpLogsRequest {
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	req := otlpLogsRequest{ResourceLogs: []otlpResourceLogs{}}
+	for _, a := range hpa {
+		attrs := map[string]string{"service.name": "hpa-exporter"}
+		for k, v := range parseKeyValues(*otlpResourceAttributes) {
+			attrs[k] = v
+		}
+		attrs["k8s.namespace.name"] = a.ObjectMeta.Namespace
+		attrs["k8s.hpa.name"] = a.ObjectMeta.Name
+		rl := otlpResourceLogs{}
+		rl.Resource.Attributes = otlpAttributes(attrs)
+		sl := otlpScopeLogs{}
+		sl.Scope.Name = "hpa-exporter/conditions"
+		for _, l := range conditionLogLines([]as_v2.HorizontalPodAutoscaler{a}) {
+			sl.LogRecords = append(sl.LogRecords, otlpLogRecord{
+				TimeUnixNano:         ts,
+				ObservedTimeUnixNano: ts,
+				SeverityNumber:       otlpSeverityInfo,
+				SeverityText:         "INFO",
+				Body:                 otlpValue{l},
+			})
+		}
+		if len(sl.LogRecords) == 0 {
+			continue
+		}
+		rl.ScopeLogs = []otlpScopeLogs{sl}
+		req.ResourceLogs = append(req.ResourceLogs, rl)
+	}
+	return req
+}
+
+func exportOTLPLogs(hpa []as_v2.HorizontalPodAutoscaler) error {
+	req := otlpLogs(hpa, time.Now())
+	if len(req.ResourceLogs) == 0 {
+		return nil
+	}
+	client, err := otlpHTTPClient()
+	if err != nil {
+		return err
+	}
+	return postJSON(client, *otlpLogsEndpoint, otlpHeader(), req)
+}
+
+func otlpHeader() http.Header {
+	header := http.Header{}
+	for k, v := range parseKeyValues(otlpHeaders.Get()) {
+		header.Set(k, v)
+	}
+	return header
+}
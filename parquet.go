@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// parquet.go writes Parquet files with a flat schema of required columns,
+// in one row group of one uncompressed, PLAIN encoded data page per column.
+// That is all the snapshots need and every Parquet reader understands it.
+
+// physical types, converted types and enums of parquet.thrift
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetNoConverted     = -1
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetRequired     = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn holds the PLAIN encoded values of a column.
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32
+	values    bytes.Buffer
+}
+
+func (c *parquetColumn) addString(s string) {
+	binary.Write(&c.values, binary.LittleEndian, uint32(len(s)))
+	c.values.WriteString(s)
+}
+
+func (c *parquetColumn) addInt32(v int32) {
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) addInt64(v int64) {
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) addDouble(v float64) {
+	binary.Write(&c.values, binary.LittleEndian, math.Float64bits(v))
+}
+
+// thriftWriter writes the Thrift compact protocol, in which the metadata
+// of Parquet files is encoded.
+type thriftWriter struct {
+	buf  *bytes.Buffer
+	last []int16
+}
+
+// compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+func (t *thriftWriter) varint(v uint64) {
+	for v >= 0x80 {
+		t.buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	t.buf.WriteByte(byte(v))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(uint64(uint16((id << 1) ^ (id >> 15))))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elem)
+	t.varint(uint64(n))
+}
+
+// begin starts a struct, the field id is that of the enclosing struct or
+// 0 for an element of a list or the top level struct.
+func (t *thriftWriter) begin(id int16) {
+	if id != 0 {
+		t.field(id, thriftStruct)
+	}
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// writeParquet writes the columns, each holding rows values, as a Parquet
+// file.
+func writeParquet(buf *bytes.Buffer, cols []*parquetColumn, rows int) {
+	base := buf.Len()
+	buf.Write(parquetMagic)
+	offsets := make([]int64, len(cols))
+	sizes := make([]int64, len(cols))
+	for i, c := range cols {
+		offsets[i] = int64(buf.Len() - base)
+		header := &thriftWriter{buf: &bytes.Buffer{}}
+		header.begin(0)
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(c.values.Len()))
+		header.i32(3, int32(c.values.Len()))
+		header.begin(5)
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+		sizes[i] = int64(header.buf.Len() + c.values.Len())
+		buf.Write(header.buf.Bytes())
+		buf.Write(c.values.Bytes())
+	}
+
+	footer := buf.Len()
+	t := &thriftWriter{buf: buf}
+	t.begin(0)
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(cols)+1)
+	t.begin(0)
+	t.str(4, "schema")
+	t.i32(5, int32(len(cols)))
+	t.end()
+	for _, c := range cols {
+		t.begin(0)
+		t.i32(1, c.physical)
+		t.i32(3, parquetRequired)
+		t.str(4, c.name)
+		if c.converted != parquetNoConverted {
+			t.i32(6, c.converted)
+		}
+		t.end()
+	}
+	t.i64(3, int64(rows))
+	t.list(4, thriftStruct, 1)
+	t.begin(0)
+	t.list(1, thriftStruct, len(cols))
+	var total int64
+	for i, c := range cols {
+		t.begin(0)
+		t.i64(2, offsets[i])
+		t.begin(3)
+		t.i32(1, c.physical)
+		t.list(2, thriftI32, 1)
+		t.varint(parquetPlain)
+		t.list(3, thriftBinary, 1)
+		t.binary(c.name)
+		t.i32(4, parquetUncompressed)
+		t.i64(5, int64(rows))
+		t.i64(6, sizes[i])
+		t.i64(7, sizes[i])
+		t.i64(9, offsets[i])
+		t.end()
+		t.end()
+		total += sizes[i]
+	}
+	t.i64(2, total)
+	t.i64(3, int64(rows))
+	t.end()
+	t.str(6, "hpa-exporter")
+	t.end()
+	binary.Write(buf, binary.LittleEndian, uint32(buf.Len()-footer))
+	buf.Write(parquetMagic)
+}
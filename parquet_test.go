@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+// thriftReader decodes the Thrift compact protocol into maps of the field
+// ids for structs, slices for lists and int64, float64 or string values.
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+func (t *thriftReader) varint() int64 {
+	v, _ := binary.ReadUvarint(t.r)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 4, 5, 6:
+		return t.varint()
+	case 7:
+		var v float64
+		binary.Read(t.r, binary.LittleEndian, &v)
+		return v
+	case 8:
+		n, _ := binary.ReadUvarint(t.r)
+		b := make([]byte, n)
+		t.r.Read(b)
+		return string(b)
+	case 9:
+		h, _ := t.r.ReadByte()
+		n := int64(h >> 4)
+		if n == 15 {
+			v, _ := binary.ReadUvarint(t.r)
+			n = int64(v)
+		}
+		ret := []interface{}{}
+		for i := int64(0); i < n; i++ {
+			ret = append(ret, t.value(h&0x0f))
+		}
+		return ret
+	case 12:
+		ret := map[int16]interface{}{}
+		var last int16
+		for {
+			h, err := t.r.ReadByte()
+			if err != nil || h == 0 {
+				return ret
+			}
+			if d := int16(h >> 4); d != 0 {
+				last += d
+			} else {
+				last = int16(t.varint())
+			}
+			ret[last] = t.value(h & 0x0f)
+		}
+	}
+	panic("unknown thrift type")
+}
+
+func TestWriteSnapshotParquet(t *testing.T) {
+	hpa := make([]as_v2.HorizontalPodAutoscaler, 2)
+	hpa[0].ObjectMeta.Namespace, hpa[0].ObjectMeta.Name = "prod", "web"
+	hpa[0].Spec.MaxReplicas = 10
+	hpa[1].ObjectMeta.Namespace, hpa[1].ObjectMeta.Name = "prod", "worker"
+	hpa[1].Spec.MaxReplicas = 4
+	buf := &bytes.Buffer{}
+	writeSnapshotParquet(buf, hpa, testTime)
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, parquetMagic) || !bytes.HasSuffix(b, parquetMagic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	footer := b[len(b)-8-n : len(b)-8]
+	meta := (&thriftReader{bytes.NewReader(footer)}).value(12).(map[int16]interface{})
+	if meta[3] != int64(2) {
+		t.Fatalf("got %v rows, want 2", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 14 || schema[0].(map[int16]interface{})[5] != int64(13) {
+		t.Fatalf("got schema %v, want the root and 13 columns", schema)
+	}
+	chunks := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+
+	// values reads the PLAIN encoded values of column i from its page
+	values := func(i int) *bytes.Reader {
+		md := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		r := bytes.NewReader(b[md[9].(int64):])
+		page := (&thriftReader{r}).value(12).(map[int16]interface{})
+		if page[5].(map[int16]interface{})[1] != int64(2) {
+			t.Fatalf("column %d: got page %v, want 2 values", i, page)
+		}
+		return r
+	}
+	for i, want := range map[int]string{2: "name", 5: "min_replicas", 12: "demand_replicas"} {
+		if got := schema[i+1].(map[int16]interface{})[4]; got != want {
+			t.Errorf("got column %d %v, want %s", i, got, want)
+		}
+	}
+
+	var ts int64
+	binary.Read(values(0), binary.LittleEndian, &ts)
+	if ts != testTime.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("got snapshot time %d", ts)
+	}
+	r := values(2)
+	for _, want := range []string{"web", "worker"} {
+		var l uint32
+		binary.Read(r, binary.LittleEndian, &l)
+		s := make([]byte, l)
+		r.Read(s)
+		if string(s) != want {
+			t.Errorf("got name %q, want %s", s, want)
+		}
+	}
+	var min, max [2]int32
+	binary.Read(values(5), binary.LittleEndian, &min)
+	binary.Read(values(6), binary.LittleEndian, &max)
+	if min != [2]int32{1, 1} || max != [2]int32{10, 4} {
+		t.Errorf("got min %v max %v", min, max)
+	}
+	var demand uint64
+	binary.Read(values(12), binary.LittleEndian, &demand)
+	if d := math.Float64frombits(demand); d != demandReplicas(hpa[0]) {
+		t.Errorf("got demand %v, want %v", d, demandReplicas(hpa[0]))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/prometheus/client_golang/prometheus"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	policy_v1beta1 "k8s.io/api/policy/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var checkPDB = flag.Bool("checkPDB", defaultCheckPDB, "Find the PodDisruptionBudgets selecting the pods of each HPA's scale target and report those that conflict with minReplicas or maxReplicas. Needs `get` on the scale targets and `list` on poddisruptionbudgets.")
+
+// cyclePDBs caches the PodDisruptionBudgets of each namespace within a
+// collection. It is only accessed while holding collectMu.
+var cyclePDBs = map[string][]policy_v1beta1.PodDisruptionBudget{}
+
+func resetPDBCache() {
+	cyclePDBs = map[string][]policy_v1beta1.PodDisruptionBudget{}
+}
+
+func namespacePDBs(ns string) ([]policy_v1beta1.PodDisruptionBudget, error) {
+	if l, ok := cyclePDBs[ns]; ok {
+		return l, nil
+	}
+	l, err := kubeClient.PolicyV1beta1().PodDisruptionBudgets(ns).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cyclePDBs[ns] = l.Items
+	return l.Items, nil
+}
+
+// pdbMinAvailable returns how many of replicas pods the PDB keeps
+// available, rounding percentages up like the disruption controller.
+func pdbMinAvailable(pdb policy_v1beta1.PodDisruptionBudget, replicas int32) (int32, error) {
+	if pdb.Spec.MaxUnavailable != nil {
+		n, err := intstr.GetValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(replicas), true)
+		if err != nil {
+			return 0, err
+		}
+		return replicas - int32(n), nil
+	}
+	if pdb.Spec.MinAvailable != nil {
+		n, err := intstr.GetValueFromIntOrPercent(pdb.Spec.MinAvailable, int(replicas), true)
+		if err != nil {
+			return 0, err
+		}
+		return int32(n), nil
+	}
+	return 0, nil
+}
+
+// detectPDBConflicts exports, for each PDB selecting the pods of the scale
+// target, the pods it keeps available at minReplicas and whether it can be
+// satisfied at minReplicas and allows a disruption at maxReplicas.
+func detectPDBConflicts(a as_v2.HorizontalPodAutoscaler, labelSet prometheus.Labels) error {
+	t, err := cachedScaleTarget(a)
+	if err != nil {
+		if _, ok := err.(unsupportedTargetError); ok {
+			return nil
+		}
+		return err
+	}
+	if len(t.PodLabels) == 0 {
+		return nil
+	}
+	pdbs, err := namespacePDBs(a.ObjectMeta.Namespace)
+	if err != nil {
+		return err
+	}
+	min := int32(1)
+	if a.Spec.MinReplicas != nil {
+		min = *a.Spec.MinReplicas
+	}
+	for _, pdb := range pdbs {
+		sel, err := meta_v1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || sel.Empty() || !sel.Matches(labels.Set(t.PodLabels)) {
+			continue
+		}
+		atMin, err := pdbMinAvailable(pdb, min)
+		if err != nil {
+			return err
+		}
+		atMax, err := pdbMinAvailable(pdb, a.Spec.MaxReplicas)
+		if err != nil {
+			return err
+		}
+		l := mergeLabels(labelSet, prometheus.Labels{"pdb": pdb.ObjectMeta.Name})
+		hpaPDBMinAvailable.With(l).Set(float64(atMin))
+		hpaPDBViolatedAtMin.With(l).Set(gaugeBool(atMin > min))
+		hpaPDBBlocksDrainAtMax.With(l).Set(gaugeBool(atMax >= a.Spec.MaxReplicas))
+	}
+	return nil
+}
+
+func gaugeBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var checkPendingPods = flag.Bool("checkPendingPods", defaultCheckPendingPods, "When an HPA wants more replicas than it has, count the pods of its scale target that cannot be scheduled or created. Needs `get` on the scale targets and `list` on pods.")
+
+// unschedulablePods counts the pods of the selector the scheduler could not
+// place.
+func unschedulablePods(ns, selector string) (int, error) {
+	l, err := kubeClient.CoreV1().Pods(ns).List(meta_v1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range l.Items {
+		if p.Status.Phase != core_v1.PodPending {
+			continue
+		}
+		for _, c := range p.Status.Conditions {
+			if c.Type == core_v1.PodScheduled && c.Status == core_v1.ConditionFalse && c.Reason == core_v1.PodReasonUnschedulable {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// detectPendingPods exports why the pods a scale-up asked for are not
+// running: Unschedulable pods wait for the scheduler or cluster-autoscaler,
+// and pods missing because of a ResourceQuota were never created.
+func detectPendingPods(a as_v2.HorizontalPodAutoscaler, labelSet prometheus.Labels) error {
+	if a.Status.DesiredReplicas <= a.Status.CurrentReplicas {
+		return nil
+	}
+	t, err := cachedScaleTarget(a)
+	if err != nil {
+		if _, ok := err.(unsupportedTargetError); ok {
+			return nil
+		}
+		return err
+	}
+	if t.Selector == "" {
+		return nil
+	}
+	n, err := unschedulablePods(a.ObjectMeta.Namespace, t.Selector)
+	if err != nil {
+		return err
+	}
+	hpaScaleBlockedPendingPods.With(mergeLabels(labelSet, prometheus.Labels{"reason": "Unschedulable"})).Set(float64(n))
+	quota := 0
+	if strings.Contains(t.ReplicaFailure, "exceeded quota") && t.SpecReplicas > t.StatusReplicas {
+		quota = int(t.SpecReplicas - t.StatusReplicas)
+	}
+	hpaScaleBlockedPendingPods.With(mergeLabels(labelSet, prometheus.Labels{"reason": "quota"})).Set(float64(quota))
+	return nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+// pgMsg frames a message of the server.
+func pgMsg(typ byte, body []byte) []byte {
+	b := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(len(body)+4))
+	return append(b, body...)
+}
+
+func pgAuth(code uint32, data []byte) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, code)
+	return pgMsg('R', append(b, data...))
+}
+
+// readPgMsg reads a message of the client.
+func readPgMsg(r io.Reader) (byte, []byte, error) {
+	h := make([]byte, 5)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(h[1:])-4)
+	_, err := io.ReadFull(r, body)
+	return h[0], body, err
+}
+
+func TestPgReceive(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		typ     byte
+		body    string
+		wantErr string
+	}{
+		{"ready", pgMsg('Z', []byte("I")), 'Z', "I", ""},
+		{"empty body", pgMsg('1', nil), '1', "", ""},
+		{"length below 4", []byte{'Z', 0, 0, 0, 3}, 0, "", "invalid length 3"},
+		{"negative length", []byte{'Z', 0xff, 0xff, 0xff, 0xff}, 0, "", "invalid length -1"},
+		{"truncated", pgMsg('Z', []byte("I"))[:5], 0, "", "EOF"},
+		{"no length", []byte{'Z', 0}, 0, "", "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &pgConn{r: bufio.NewReader(bytes.NewReader(tt.in))}
+			typ, body, err := c.receive()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ != tt.typ || string(body) != tt.body {
+				t.Fatalf("got %q %q, want %q %q", typ, body, tt.typ, tt.body)
+			}
+		})
+	}
+}
+
+func TestPgSend(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go (&pgConn{conn: client}).send('Q', []byte("SELECT 1\x00"))
+	typ, body, err := readPgMsg(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != 'Q' || string(body) != "SELECT 1\x00" {
+		t.Fatalf("got %q %q", typ, body)
+	}
+}
+
+func TestPgAuthenticate(t *testing.T) {
+	md5Password := func(user, password string, salt []byte) string {
+		inner := md5.Sum([]byte(password + user))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+		return "md5" + hex.EncodeToString(outer[:]) + "\x00"
+	}
+	salt := []byte{1, 2, 3, 4}
+	tests := []struct {
+		name string
+		// the server sends the first message, then expects the password
+		// message want and answers with reply
+		first, reply []byte
+		want         string
+		wantErr      string
+	}{
+		{"trust", pgAuth(0, nil), nil, "", ""},
+		{"cleartext", pgAuth(3, nil), pgAuth(0, nil), "secret\x00", ""},
+		{"md5", pgAuth(5, salt), pgAuth(0, nil), md5Password("hpa", "secret", salt), ""},
+		{"md5 without salt", pgAuth(5, nil), nil, "", "without salt"},
+		{"rejected", pgAuth(3, nil), pgMsg('E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00")), "secret\x00", "28P01: password authentication failed"},
+		{"unsupported", pgAuth(7, nil), nil, "", "unsupported authentication method 7"},
+		{"no SCRAM-SHA-256", pgAuth(10, []byte("SCRAM-SHA-256-PLUS\x00\x00")), nil, "", "no supported SASL mechanism"},
+		{"unexpected", pgMsg('Z', []byte("I")), nil, "", "unexpected message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer server.Close()
+				server.Write(tt.first)
+				if tt.reply == nil {
+					return
+				}
+				typ, body, err := readPgMsg(server)
+				if err != nil {
+					return
+				}
+				if typ != 'p' || string(body) != tt.want {
+					t.Errorf("got password message %q %q, want %q", typ, body, tt.want)
+				}
+				server.Write(tt.reply)
+			}()
+			c := &pgConn{conn: client, r: bufio.NewReader(client)}
+			err := c.authenticate("hpa", "secret")
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPgExec(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		if _, _, err := readPgMsg(server); err != nil {
+			return
+		}
+		server.Write(pgMsg('E', []byte("SERROR\x00C42P01\x00Mrelation \"hpa\" does not exist\x00\x00")))
+		server.Write(pgMsg('E', []byte("SERROR\x00CXX000\x00Msecond\x00\x00")))
+		server.Write(pgMsg('Z', []byte("I")))
+	}()
+	c := &pgConn{conn: client, r: bufio.NewReader(client)}
+	err := c.Exec("INSERT INTO hpa VALUES (1)")
+	e, ok := err.(*pgError)
+	if !ok {
+		t.Fatalf("got %v, want a *pgError", err)
+	}
+	if e.Severity != "ERROR" || e.Code != "42P01" || e.Message != `relation "hpa" does not exist` {
+		t.Fatalf("got %+v, want the first error", e)
+	}
+}
+
+// TestScramRFC7677 runs the example exchange of RFC 7677, section 3.
+func TestScramRFC7677(t *testing.T) {
+	s := &scramClient{user: "user", password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO"}
+	if got := s.clientFirst(); got != "n,,n=user,r=rOprNGfwEbeRWgbNEkqO" {
+		t.Fatalf("got client first %s", got)
+	}
+	final, err := s.clientFinal("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="; final != want {
+		t.Fatalf("got client final %s, want %s", final, want)
+	}
+	if !s.verifyServer("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=") {
+		t.Fatal("server signature of the RFC not verified")
+	}
+	if s.verifyServer("v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=") || s.verifyServer("e=invalid-proof") {
+		t.Fatal("invalid server signature verified")
+	}
+}
+
+func TestScramInvalidServerFirst(t *testing.T) {
+	for _, serverFirst := range []string{
+		"r=other%nonce,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+		"r=rOprNGfwEbeRWgbNEkqO%x,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=0",
+		"r=rOprNGfwEbeRWgbNEkqO%x,s=not base64,i=4096",
+		"",
+	} {
+		s := &scramClient{password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO"}
+		s.clientFirst()
+		if _, err := s.clientFinal(serverFirst); err == nil {
+			t.Errorf("%q: want an error", serverFirst)
+		}
+	}
+}
+
+func TestScramUserName(t *testing.T) {
+	s := &scramClient{user: "a=b,c", nonce: "n"}
+	if got := s.clientFirst(); got != "n,,n=a=3Db=2Cc,r=n" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestPgQuote(t *testing.T) {
+	if got := pgQuote("it's\x00"); got != "E'it''s'" {
+		t.Errorf("got %s", got)
+	}
+	// a backslash escaping the quote would end the literal with
+	// standard_conforming_strings off
+	if got := pgQuote(`\'); DROP TABLE hpa; --`); got != `E'\\''); DROP TABLE hpa; --'` {
+		t.Errorf("got %s", got)
+	}
+	if got := pgIdent(`public.hpa"s`); got != `"public"."hpa""s"` {
+		t.Errorf("got %s", got)
+	}
+}
+
+// pgStep is a message the server expects from the client, by type and a
+// part of its body, and the messages it answers with.
+type pgStep struct {
+	typ      byte
+	contains string
+	reply    [][]byte
+}
+
+// pgServer replays a recorded exchange with a client on the connection
+// accepted by l and sends the first unexpected message, or nil, to done.
+func pgServer(l net.Listener, steps []pgStep, done chan<- error) {
+	conn, err := l.Accept()
+	if err != nil {
+		done <- err
+		return
+	}
+	defer conn.Close()
+	for _, st := range steps {
+		var typ byte
+		var body []byte
+		if st.typ == 0 {
+			// the startup message has no type
+			h := make([]byte, 4)
+			if _, err = io.ReadFull(conn, h); err == nil {
+				body = make([]byte, binary.BigEndian.Uint32(h)-4)
+				_, err = io.ReadFull(conn, body)
+			}
+		} else {
+			typ, body, err = readPgMsg(conn)
+		}
+		if err != nil {
+			done <- err
+			return
+		}
+		if typ != st.typ || !strings.Contains(string(body), st.contains) {
+			done <- fmt.Errorf("got %q %q, want %q with %q", typ, body, st.typ, st.contains)
+			return
+		}
+		for _, r := range st.reply {
+			conn.Write(r)
+		}
+	}
+	done <- nil
+}
+
+func TestPostgresSinkTranscript(t *testing.T) {
+	defer func(u, table string) { *postgresURL.value, *postgresTable = u, table }(*postgresURL.value, *postgresTable)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	*postgresURL.value = "postgres://hpa:secret@" + l.Addr().String() + "/metrics"
+	*postgresTable = "hpa"
+	salt := []byte{0x8e, 0x1c, 0x22, 0x5a}
+	inner := md5.Sum([]byte("secrethpa"))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	ready := pgMsg('Z', []byte("I"))
+	done := make(chan error, 1)
+	go pgServer(l, []pgStep{
+		{0, "user\x00hpa\x00database\x00metrics\x00", [][]byte{pgAuth(5, salt)}},
+		{'p', "md5" + hex.EncodeToString(outer[:]), [][]byte{
+			pgAuth(0, nil),
+			pgMsg('S', []byte("server_version\x0011.4\x00")),
+			pgMsg('S', []byte("standard_conforming_strings\x00off\x00")),
+			pgMsg('K', []byte{0, 0, 0x30, 0x39, 0x12, 0x34, 0x56, 0x78}),
+			ready,
+		}},
+		{'Q', `CREATE TABLE IF NOT EXISTS "hpa" (`, [][]byte{
+			pgMsg('N', []byte("SNOTICE\x00C42P07\x00Mrelation \"hpa\" already exists, skipping\x00\x00")),
+			pgMsg('C', []byte("CREATE TABLE\x00")),
+			pgMsg('C', []byte("CREATE INDEX\x00")),
+			ready,
+		}},
+		{'Q', `"message":"x\\\\''); DROP TABLE hpa; --"`, [][]byte{pgMsg('C', []byte("INSERT 0 1\x00")), ready}},
+		{'X', "", nil},
+	}, done)
+
+	hpa := trackerHpa(2)
+	hpa[0].Status.Conditions = []as_v2.HorizontalPodAutoscalerCondition{{Type: as_v2.ScalingActive, Message: `x\'); DROP TABLE hpa; --`}}
+	s := newPostgresSink()
+	if err := s.Write(hpa, testTime); err != nil {
+		t.Fatal(err)
+	}
+	s.conn.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
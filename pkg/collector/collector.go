@@ -0,0 +1,163 @@
+// Package collector is a prometheus.Collector of the core metrics of the
+// hpa-exporter, for programs that embed it instead of running the exporter.
+// It reads the HPAs on every scrape through the kubernetes.Interface it is
+// given, so it can be tested with a fake client.
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/prometheus/client_golang/prometheus"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BaseLabels identify the HPA and its scale target.
+var BaseLabels = []string{
+	"hpa_name",
+	"hpa_namespace",
+	"ref_kind",
+	"ref_name",
+	"ref_apiversion",
+}
+
+// MetricLabels identify a metric of the HPA's spec or status.
+var MetricLabels = []string{
+	"metric_kind",
+	"metric_name",
+	"metric_metricname",
+	"metric_target_apiversion",
+}
+
+// ConditionLabels describe the status of an HPA condition.
+var ConditionLabels = []string{
+	"cond_status",
+	"cond_reason",
+	"cond_message",
+	"cond_message_hash",
+}
+
+// BaseLabelValues returns the values of BaseLabels for the HPA.
+func BaseLabelValues(a as_v2.HorizontalPodAutoscaler) []string {
+	return []string{
+		a.ObjectMeta.Name,
+		a.ObjectMeta.Namespace,
+		a.Spec.ScaleTargetRef.Kind,
+		a.Spec.ScaleTargetRef.Name,
+		a.Spec.ScaleTargetRef.APIVersion,
+	}
+}
+
+// ConditionMessageHash is a short stable hash of a condition message, so
+// that identical failures can be grouped without the message text.
+func ConditionMessageHash(s string) string {
+	if s == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:4])
+}
+
+// Collector exports the replicas, metrics and conditions of the HPAs of a
+// namespace, or of all namespaces.
+type Collector struct {
+	client    kubernetes.Interface
+	namespace string
+
+	currentPods    *prometheus.Desc
+	desiredPods    *prometheus.Desc
+	minPods        *prometheus.Desc
+	maxPods        *prometheus.Desc
+	lastScale      *prometheus.Desc
+	currentMetrics *prometheus.Desc
+	targetMetrics  *prometheus.Desc
+	conditions     map[as_v2.HorizontalPodAutoscalerConditionType]*prometheus.Desc
+}
+
+// New returns a Collector of the HPAs in namespace, all namespaces when it
+// is "".
+func New(client kubernetes.Interface, namespace string) *Collector {
+	metricLabels := append(append([]string{}, BaseLabels...), MetricLabels...)
+	condLabels := append(append([]string{}, BaseLabels...), ConditionLabels...)
+	return &Collector{
+		client:         client,
+		namespace:      namespace,
+		currentPods:    prometheus.NewDesc("hpa_current_pods_num", "Number of current pods by status.", BaseLabels, nil),
+		desiredPods:    prometheus.NewDesc("hpa_desired_pods_num", "Number of desired pods by status.", BaseLabels, nil),
+		minPods:        prometheus.NewDesc("hpa_min_pods_num", "Number of min pods by spec.", BaseLabels, nil),
+		maxPods:        prometheus.NewDesc("hpa_max_pods_num", "Number of max pods by spec.", BaseLabels, nil),
+		lastScale:      prometheus.NewDesc("hpa_last_scale_second", "Time the scale was last executed.", BaseLabels, nil),
+		currentMetrics: prometheus.NewDesc("hpa_current_metrics_value", "Current Metrics Value.", metricLabels, nil),
+		targetMetrics:  prometheus.NewDesc("hpa_target_metrics_value", "Target Metrics Value.", metricLabels, nil),
+		conditions: map[as_v2.HorizontalPodAutoscalerConditionType]*prometheus.Desc{
+			as_v2.AbleToScale:    prometheus.NewDesc("hpa_able_to_scale", "status able to scale from annotation.", condLabels, nil),
+			as_v2.ScalingActive:  prometheus.NewDesc("hpa_scaling_active", "status scaling active from annotation.", condLabels, nil),
+			as_v2.ScalingLimited: prometheus.NewDesc("hpa_scaling_limited", "status scaling limited from annotation.", condLabels, nil),
+		},
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.currentPods
+	ch <- c.desiredPods
+	ch <- c.minPods
+	ch <- c.maxPods
+	ch <- c.lastScale
+	ch <- c.currentMetrics
+	ch <- c.targetMetrics
+	for _, d := range c.conditions {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector. A failure to list the HPAs is
+// reported as an invalid metric, which fails the scrape.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	l, err := c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(c.namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.currentPods, err)
+		return
+	}
+	for _, a := range l.Items {
+		c.collectHpa(ch, a)
+	}
+}
+
+func (c *Collector) collectHpa(ch chan<- prometheus.Metric, a as_v2.HorizontalPodAutoscaler) {
+	base := BaseLabelValues(a)
+	gauge := func(d *prometheus.Desc, v float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, append(append([]string{}, base...), labelValues...)...)
+	}
+	gauge(c.currentPods, float64(a.Status.CurrentReplicas))
+	gauge(c.desiredPods, float64(a.Status.DesiredReplicas))
+	if a.Spec.MinReplicas != nil {
+		gauge(c.minPods, float64(*a.Spec.MinReplicas))
+	}
+	gauge(c.maxPods, float64(a.Spec.MaxReplicas))
+	if a.Status.LastScaleTime != nil {
+		gauge(c.lastScale, float64(a.Status.LastScaleTime.Unix()))
+	}
+	for _, m := range SpecMetrics(a) {
+		gauge(c.targetMetrics, m.Value, MetricLabelValues(m)...)
+	}
+	for _, m := range StatusMetrics(a) {
+		gauge(c.currentMetrics, m.Value, MetricLabelValues(m)...)
+	}
+	for _, cond := range a.Status.Conditions {
+		d, ok := c.conditions[cond.Type]
+		if !ok {
+			continue
+		}
+		// the opposite status is exported as 0 so that both series exist
+		reverse := core_v1.ConditionTrue
+		if cond.Status == core_v1.ConditionTrue {
+			reverse = core_v1.ConditionFalse
+		}
+		gauge(d, 1, string(cond.Status), cond.Reason, cond.Message, ConditionMessageHash(cond.Message))
+		gauge(d, 0, string(reverse), "", "", "")
+	}
+}
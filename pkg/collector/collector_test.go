@@ -0,0 +1,109 @@
+package collector_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildsville/hpa-exporter/pkg/collector"
+	"github.com/buildsville/hpa-exporter/pkg/collector/collectortest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/client-go/kubernetes"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of testdata.")
+
+// exposition returns the metrics of a collector in the text format.
+func exposition(t *testing.T, client kubernetes.Interface, ns string) (string, error) {
+	t.Helper()
+	r := prometheus.NewPedanticRegistry()
+	if err := r.Register(collector.New(client, ns)); err != nil {
+		t.Fatal(err)
+	}
+	mfs, err := r.Gather()
+	if err != nil {
+		return "", err
+	}
+	b := &bytes.Buffer{}
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(b, mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.String(), nil
+}
+
+// TestGolden compares the exposition of the HPAs of each testdata/*.json
+// with testdata/*.prom. Run with -update to rewrite them.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata")
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".json")
+		t.Run(name, func(t *testing.T) {
+			client, err := collectortest.LoadClient(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := exposition(t, client, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(f, ".json") + ".prom"
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("exposition differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	client, err := collectortest.LoadClient("testdata/custom.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := exposition(t, client, "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `hpa_name="worker"`) || strings.Contains(got, `hpa_name="api"`) {
+		t.Errorf("want only the HPAs of namespace batch, got:\n%s", got)
+	}
+}
+
+func TestListError(t *testing.T) {
+	client := collectortest.NewClient()
+	client.Err = fmt.Errorf("forbidden")
+	if _, err := exposition(t, client, ""); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("want the list error, got %v", err)
+	}
+}
+
+func TestNoHpas(t *testing.T) {
+	got, err := exposition(t, collectortest.NewClient(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("want no metrics, got:\n%s", got)
+	}
+}
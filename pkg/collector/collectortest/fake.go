@@ -0,0 +1,77 @@
+// Package collectortest provides a fake Kubernetes client serving HPAs, for
+// testing the collector without a cluster.
+package collectortest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	autoscaling_v2beta1 "k8s.io/client-go/kubernetes/typed/autoscaling/v2beta1"
+)
+
+// Client is a kubernetes.Interface that lists HPAs from memory. Only
+// AutoscalingV2beta1().HorizontalPodAutoscalers(ns).List is implemented,
+// the other methods panic.
+type Client struct {
+	kubernetes.Interface
+
+	HPAs []as_v2.HorizontalPodAutoscaler
+	// Err is returned by List when set.
+	Err error
+}
+
+// NewClient returns a Client serving the HPAs.
+func NewClient(hpa ...as_v2.HorizontalPodAutoscaler) *Client {
+	return &Client{HPAs: hpa}
+}
+
+// LoadClient returns a Client serving the HPAs of a JSON
+// HorizontalPodAutoscalerList file, e.g. the output of
+// `kubectl get hpa.v2beta1.autoscaling -o json`.
+func LoadClient(path string) (*Client, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := as_v2.HorizontalPodAutoscalerList{}
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, err
+	}
+	return NewClient(l.Items...), nil
+}
+
+// AutoscalingV2beta1 implements kubernetes.Interface.
+func (c *Client) AutoscalingV2beta1() autoscaling_v2beta1.AutoscalingV2beta1Interface {
+	return autoscalingClient{client: c}
+}
+
+type autoscalingClient struct {
+	autoscaling_v2beta1.AutoscalingV2beta1Interface
+	client *Client
+}
+
+func (c autoscalingClient) HorizontalPodAutoscalers(ns string) autoscaling_v2beta1.HorizontalPodAutoscalerInterface {
+	return hpaClient{client: c.client, namespace: ns}
+}
+
+type hpaClient struct {
+	autoscaling_v2beta1.HorizontalPodAutoscalerInterface
+	client    *Client
+	namespace string
+}
+
+func (c hpaClient) List(opts meta_v1.ListOptions) (*as_v2.HorizontalPodAutoscalerList, error) {
+	if c.client.Err != nil {
+		return nil, c.client.Err
+	}
+	l := &as_v2.HorizontalPodAutoscalerList{}
+	for _, a := range c.client.HPAs {
+		if c.namespace == "" || a.ObjectMeta.Namespace == c.namespace {
+			l.Items = append(l.Items, a)
+		}
+	}
+	return l, nil
+}
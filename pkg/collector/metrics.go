@@ -0,0 +1,173 @@
+package collector
+
+import (
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+// Metric is a metric of an HPA's spec or status, keyed by the kind and name
+// of what it describes and its metric name. Kind is Pod, Resource or
+// External for those metric types and the kind of the described object for
+// Object metrics; Name and MetricName are "-" when they do not apply.
+type Metric struct {
+	Kind       string
+	Name       string
+	MetricName string
+	// TargetAPIVersion is the apiVersion of the described object of Object
+	// metrics, e.g. networking.k8s.io/v1 for an Ingress.
+	TargetAPIVersion string
+	Value            float64
+}
+
+// SpecMetrics returns the target of each metric of the HPA's spec.
+// Resource targets are percents of the request when given as utilization.
+func SpecMetrics(a as_v2.HorizontalPodAutoscaler) []Metric {
+	ret := []Metric{}
+	for _, metric := range a.Spec.Metrics {
+		if m, ok := SpecMetric(metric); ok {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// SpecMetric returns the target of a metric of an HPA's spec, false for
+// unknown metric types.
+func SpecMetric(metric as_v2.MetricSpec) (Metric, bool) {
+	switch metric.Type {
+	case as_v2.ObjectMetricSourceType:
+		return parseObjectSpec(metric.Object), true
+	case as_v2.PodsMetricSourceType:
+		return parsePodsSpec(metric.Pods), true
+	case as_v2.ResourceMetricSourceType:
+		return parseResourceSpec(metric.Resource), true
+	case as_v2.ExternalMetricSourceType:
+		return parseExternalSpec(metric.External), true
+	}
+	return Metric{}, false
+}
+
+// StatusMetrics returns the current value of each metric of the HPA's
+// status.
+func StatusMetrics(a as_v2.HorizontalPodAutoscaler) []Metric {
+	ret := []Metric{}
+	for _, metric := range a.Status.CurrentMetrics {
+		switch metric.Type {
+		case as_v2.ObjectMetricSourceType:
+			ret = append(ret, parseObjectStatus(metric.Object))
+		case as_v2.PodsMetricSourceType:
+			ret = append(ret, parsePodsStatus(metric.Pods))
+		case as_v2.ResourceMetricSourceType:
+			ret = append(ret, parseResourceStatus(metric.Resource))
+		case as_v2.ExternalMetricSourceType:
+			ret = append(ret, parseExternalStatus(metric.External))
+		}
+	}
+	return ret
+}
+
+func parseObjectSpec(m *as_v2.ObjectMetricSource) Metric {
+	return Metric{
+		Kind:             m.Target.Kind,
+		Name:             m.Target.Name,
+		MetricName:       m.MetricName,
+		TargetAPIVersion: m.Target.APIVersion,
+		Value:            float64(m.TargetValue.MilliValue()) / 1000,
+	}
+}
+
+func parsePodsSpec(m *as_v2.PodsMetricSource) Metric {
+	return Metric{
+		Kind:       "Pod",
+		Name:       "-",
+		MetricName: m.MetricName,
+		Value:      float64(m.TargetAverageValue.MilliValue()) / 1000,
+	}
+}
+
+func parseResourceSpec(m *as_v2.ResourceMetricSource) Metric {
+	var t float64
+	if m.TargetAverageUtilization == nil {
+		t = float64(m.TargetAverageValue.MilliValue()) / 1000
+	} else {
+		t = float64(*m.TargetAverageUtilization)
+	}
+	return Metric{
+		Kind:       "Resource",
+		Name:       m.Name.String(),
+		MetricName: "-",
+		Value:      t,
+	}
+}
+
+func parseExternalSpec(m *as_v2.ExternalMetricSource) Metric {
+	var t float64
+	if m.TargetAverageValue == nil {
+		t = float64(m.TargetValue.MilliValue()) / 1000
+	} else {
+		t = float64(m.TargetAverageValue.MilliValue()) / 1000
+	}
+	return Metric{
+		Kind:       "External",
+		Name:       "-",
+		MetricName: m.MetricName,
+		Value:      t,
+	}
+}
+
+func parseObjectStatus(m *as_v2.ObjectMetricStatus) Metric {
+	return Metric{
+		Kind:             m.Target.Kind,
+		Name:             m.Target.Name,
+		MetricName:       m.MetricName,
+		TargetAPIVersion: m.Target.APIVersion,
+		Value:            float64(m.CurrentValue.MilliValue()) / 1000,
+	}
+}
+
+func parsePodsStatus(m *as_v2.PodsMetricStatus) Metric {
+	return Metric{
+		Kind:       "Pod",
+		Name:       "-",
+		MetricName: m.MetricName,
+		Value:      float64(m.CurrentAverageValue.MilliValue()) / 1000,
+	}
+}
+
+func parseResourceStatus(m *as_v2.ResourceMetricStatus) Metric {
+	var t float64
+	if m.CurrentAverageUtilization == nil {
+		t = float64(m.CurrentAverageValue.MilliValue()) / 1000
+	} else {
+		t = float64(*m.CurrentAverageUtilization)
+	}
+	return Metric{
+		Kind:       "Resource",
+		Name:       m.Name.String(),
+		MetricName: "-",
+		Value:      t,
+	}
+}
+
+func parseExternalStatus(m *as_v2.ExternalMetricStatus) Metric {
+	var t float64
+	if m.CurrentAverageValue == nil {
+		t = float64(m.CurrentValue.MilliValue()) / 1000
+	} else {
+		t = float64(m.CurrentAverageValue.MilliValue()) / 1000
+	}
+	return Metric{
+		Kind:       "External",
+		Name:       "-",
+		MetricName: m.MetricName,
+		Value:      t,
+	}
+}
+
+// MetricLabelValues returns the values of MetricLabels for the metric.
+func MetricLabelValues(m Metric) []string {
+	apiVersion := m.TargetAPIVersion
+	if apiVersion == "" {
+		apiVersion = "-"
+	}
+	return []string{m.Kind, m.Name, m.MetricName, apiVersion}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// isKubectlPlugin reports whether the binary was installed as a kubectl
+// plugin, e.g. `kubectl-hpa_exporter` invoked as `kubectl hpa-exporter`.
+func isKubectlPlugin() bool {
+	return strings.HasPrefix(filepath.Base(os.Args[0]), "kubectl-")
+}
+
+func openDashboard() {
+	if strings.HasPrefix(*addr, unixAddrPrefix) {
+		log.Infoln("dashboard is served at", *addr)
+		return
+	}
+	for i := 0; i < 50; i++ {
+		c, err := net.Dial("tcp", *addr)
+		if err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	host, port, err := net.SplitHostPort(*addr)
+	if err != nil {
+		log.Warnln("could not open browser:", err)
+		return
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	scheme := "http"
+	if *tlsCertFile != "" {
+		scheme = "https"
+	}
+	url := scheme + "://" + net.JoinHostPort(host, port) + "/"
+	log.Infoln("dashboard is served at", url)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		log.Warnln("could not open browser:", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/prometheus/client_golang/prometheus"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var checkQuota = flag.Bool("checkQuota", defaultCheckQuota, "Report HPAs whose scale-up to maxReplicas would exceed a ResourceQuota of their namespace for CPU, memory or pods. Needs `get` on the scale targets and `list` on resourcequotas.")
+
+// cycleQuotas caches the ResourceQuotas of each namespace within a
+// collection. It is only accessed while holding collectMu.
+var cycleQuotas = map[string][]core_v1.ResourceQuota{}
+
+func resetQuotaCache() {
+	cycleQuotas = map[string][]core_v1.ResourceQuota{}
+}
+
+func namespaceQuotas(ns string) ([]core_v1.ResourceQuota, error) {
+	if l, ok := cycleQuotas[ns]; ok {
+		return l, nil
+	}
+	l, err := kubeClient.CoreV1().ResourceQuotas(ns).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cycleQuotas[ns] = l.Items
+	return l.Items, nil
+}
+
+// quotaUsage returns what a pod of the template counts against each quota
+// resource, in cores, bytes or pods.
+func quotaUsage(r podResources) map[core_v1.ResourceName]float64 {
+	return map[core_v1.ResourceName]float64{
+		core_v1.ResourceCPU:            r.CPURequest,
+		core_v1.ResourceRequestsCPU:    r.CPURequest,
+		core_v1.ResourceLimitsCPU:      r.CPULimit,
+		core_v1.ResourceMemory:         r.MemoryRequest,
+		core_v1.ResourceRequestsMemory: r.MemoryRequest,
+		core_v1.ResourceLimitsMemory:   r.MemoryLimit,
+		core_v1.ResourcePods:           1,
+	}
+}
+
+func quotaValue(q core_v1.ResourceList, name core_v1.ResourceName) float64 {
+	v := q[name]
+	if name == core_v1.ResourceCPU || name == core_v1.ResourceRequestsCPU || name == core_v1.ResourceLimitsCPU {
+		return float64(v.MilliValue()) / 1000
+	}
+	return float64(v.Value())
+}
+
+// detectQuotaLimits exports, for each resource of each quota of the
+// namespace, whether adding the pods of a scale-up to maxReplicas to what
+// is already used would exceed the hard limit. Scoped quotas are skipped
+// since they may not count the pods.
+func detectQuotaLimits(a as_v2.HorizontalPodAutoscaler, labelSet prometheus.Labels) error {
+	t, err := cachedScaleTarget(a)
+	if err != nil {
+		if _, ok := err.(unsupportedTargetError); ok {
+			return nil
+		}
+		return err
+	}
+	if t.Subresource {
+		return nil
+	}
+	quotas, err := namespaceQuotas(a.ObjectMeta.Namespace)
+	if err != nil {
+		return err
+	}
+	n := a.Spec.MaxReplicas - a.Status.CurrentReplicas
+	if n < 0 {
+		n = 0
+	}
+	usage := quotaUsage(t.PodResources)
+	for _, q := range quotas {
+		if len(q.Spec.Scopes) > 0 || q.Spec.ScopeSelector != nil {
+			continue
+		}
+		for name := range q.Status.Hard {
+			u, ok := usage[name]
+			if !ok {
+				continue
+			}
+			limited := quotaValue(q.Status.Used, name)+float64(n)*u > quotaValue(q.Status.Hard, name)
+			hpaQuotaLimited.With(mergeLabels(labelSet, prometheus.Labels{
+				"quota":    q.ObjectMeta.Name,
+				"resource": string(name),
+			})).Set(gaugeBool(limited))
+		}
+	}
+	return nil
+}
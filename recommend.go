@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"sort"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var recommendWindow = flag.Duration("recommendWindow", defaultRecommendWindow, "Window of observed demand used for min/max replicas recommendations.")
+var recommendMinPercentile = flag.Float64("recommendMinPercentile", defaultRecommendMinPercentile, "Percentile of observed demand recommended as min replicas.")
+var recommendMaxPercentile = flag.Float64("recommendMaxPercentile", defaultRecommendMaxPercentile, "Percentile of observed demand recommended as max replicas.")
+
+// demand samples are kept at most once per minute to bound memory.
+const demandResolution = time.Minute
+
+type demandSample struct {
+	Time  time.Time
+	Value float64
+}
+
+// demandReplicas estimates the replicas the workload would need without
+// the min/max bounds: current replicas scaled by the highest metric ratio.
+func demandReplicas(a as_v2.HorizontalPodAutoscaler) float64 {
+	ratios := metricRatios(a)
+	if len(ratios) == 0 || a.Status.CurrentReplicas == 0 {
+		return float64(a.Status.DesiredReplicas)
+	}
+	max := 0.0
+	for _, r := range ratios {
+		max = math.Max(max, r)
+	}
+	return float64(a.Status.CurrentReplicas) * max
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func recommendReplicas(h *hpaHistory, a as_v2.HorizontalPodAutoscaler, now time.Time) {
+	if n := len(h.demand); n == 0 || now.Sub(h.demand[n-1].Time) >= demandResolution {
+		h.demand = append(h.demand, demandSample{now, demandReplicas(a)})
+	}
+	cut := now.Add(-*recommendWindow)
+	i := 0
+	for i < len(h.demand) && h.demand[i].Time.Before(cut) {
+		i++
+	}
+	h.demand = h.demand[i:]
+
+	values := make([]float64, 0, len(h.demand))
+	for _, s := range h.demand {
+		values = append(values, s.Value)
+	}
+	sort.Float64s(values)
+	min := math.Max(1, math.Ceil(percentile(values, *recommendMinPercentile)))
+	max := math.Max(min, math.Ceil(percentile(values, *recommendMaxPercentile)))
+	hpaRecommendedMinReplicas.With(h.labels).Set(min)
+	hpaRecommendedMaxReplicas.With(h.labels).Set(max)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+
+	"github.com/buildsville/hpa-exporter/pkg/collector"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var redactPattern = flag.String("redactPattern", "", "Regular expression of text redacted from condition messages in metric labels and condition logs, e.g. `arn:aws:sqs:[^ ]+|[a-z0-9.-]+\\.internal`.")
+var redactReplacement = flag.String("redactReplacement", defaultRedactReplacement, "Text that replaces matches of redactPattern.")
+
+var condMessageLabel = flag.String("condMessageLabel", defaultCondMessageLabel, "Condition message in the cond_message label (message), or only its hash in cond_message_hash (hash) to bound cardinality. cond_message_hash is set either way.")
+
+// compileRedactPattern returns nil for an empty pattern.
+func compileRedactPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func redact(s string) string {
+	c := currentAppliedConfig()
+	if c.redact == nil {
+		return s
+	}
+	return c.redact.ReplaceAllLiteralString(s, c.values["redactReplacement"])
+}
+
+// condMessageHash is a short stable hash of the redacted message, so that
+// identical failures can be grouped without the message text.
+func condMessageHash(s string) string {
+	return collector.ConditionMessageHash(redact(s))
+}
+
+func condMessageLabelValue(s string) string {
+	if *condMessageLabel == "hash" {
+		return ""
+	}
+	return redact(s)
+}
+
+func redactConditions(cs []as_v2.HorizontalPodAutoscalerCondition) []as_v2.HorizontalPodAutoscalerCondition {
+	ret := make([]as_v2.HorizontalPodAutoscalerCondition, len(cs))
+	for i, c := range cs {
+		c.Message = redact(c.Message)
+		ret[i] = c
+	}
+	return ret
+}
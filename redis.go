@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var redisURL = newSecret("redisURL", "redis://[user:password@]host:6379[/db] URL of a Redis server to publish events to and keep the latest state of every HPA in, rediss:// for TLS. Nothing is written when empty.")
+var redisChannel = flag.String("redisChannel", defaultRedisChannel, "Redis channel condition changes and scale events are published to. Nothing is published when empty.")
+var redisKeyPrefix = flag.String("redisKeyPrefix", defaultRedisKeyPrefix, "Prefix of the Redis hash keys, followed by <namespace>/<name>, holding the latest state of every HPA. No hashes are written when empty.")
+var redisKeyTTL = flag.Duration("redisKeyTTL", defaultRedisKeyTTL, "Expiry of the Redis hashes, so hashes of HPAs deleted while the exporter was down go away.")
+var redisTLS = newClientTLS("redis", "Redis")
+
+const redisTimeout = 10 * time.Second
+
+// redisConn is a minimal RESP client which sends commands in pipelines.
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialRedis(rawurl string) (*redisConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "redis":
+		conn, err = net.DialTimeout("tcp", host, redisTimeout)
+	case "rediss":
+		conf, e := redisTLS.Config()
+		if e != nil {
+			return nil, e
+		}
+		conf.ServerName = u.Hostname()
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: redisTimeout}, "tcp", host, conf)
+	default:
+		return nil, fmt.Errorf("unsupported scheme `%s` of redisURL, must be either `redis` or `rediss`", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	cmds := [][]string{}
+	if u.User != nil {
+		if p, ok := u.User.Password(); ok && u.User.Username() != "" {
+			cmds = append(cmds, []string{"AUTH", u.User.Username(), p})
+		} else if ok {
+			cmds = append(cmds, []string{"AUTH", p})
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		cmds = append(cmds, []string{"SELECT", db})
+	}
+	if _, err := c.Do(cmds...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Do sends the commands in one pipeline and returns their replies. The
+// first error reply is returned as error after all replies are read.
+func (c *redisConn) Do(cmds ...[]string) ([]interface{}, error) {
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+	w := bufio.NewWriter(c.conn)
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "*%d\r\n", len(cmd))
+		for _, a := range cmd {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	ret := make([]interface{}, 0, len(cmds))
+	var first error
+	for range cmds {
+		v, err := c.readReply()
+		if e, ok := err.(redisError); ok {
+			if first == nil {
+				first = e
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, first
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func (c *redisConn) readReply() (interface{}, error) {
+	l, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	l = strings.TrimRight(l, "\r\n")
+	if l == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch l[0] {
+	case '+':
+		return l[1:], nil
+	case '-':
+		return nil, redisError(l[1:])
+	case ':':
+		return strconv.ParseInt(l[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		ret := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", l)
+}
+
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+type redisSink struct {
+	conn    *redisConn
+	tracker changeTracker
+	keys    map[string]bool
+}
+
+func (s *redisSink) Name() string {
+	return "redis"
+}
+
+func redisHashFields(a as_v2.HorizontalPodAutoscaler, now time.Time) []string {
+	return []string{
+		"namespace", a.ObjectMeta.Namespace,
+		"name", a.ObjectMeta.Name,
+		"ref_kind", a.Spec.ScaleTargetRef.Kind,
+		"ref_name", a.Spec.ScaleTargetRef.Name,
+		"min_replicas", strconv.Itoa(int(minReplicas(a))),
+		"max_replicas", strconv.Itoa(int(a.Spec.MaxReplicas)),
+		"current_replicas", strconv.Itoa(int(a.Status.CurrentReplicas)),
+		"desired_replicas", strconv.Itoa(int(a.Status.DesiredReplicas)),
+		"target_metrics", jsonString(metricsObject(specMetrics(a))),
+		"current_metrics", jsonString(metricsObject(statusMetrics(a))),
+		"conditions", jsonString(redactConditions(a.Status.Conditions)),
+		"updated_at", now.UTC().Format(time.RFC3339),
+	}
+}
+
+// commands returns the commands writing the HPAs, the number of changes
+// they publish and the hash keys they keep. The sink moves on to them only
+// when the commands succeeded.
+func (s *redisSink) commands(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) (cmds [][]string, changes int, keys map[string]bool) {
+	if *redisChannel != "" {
+		cs := s.tracker.changes(hpa, now)
+		for _, e := range cs {
+			b, _ := json.Marshal(e)
+			cmds = append(cmds, []string{"PUBLISH", *redisChannel, string(b)})
+		}
+		changes = len(cs)
+	}
+	if *redisKeyPrefix == "" {
+		return cmds, changes, nil
+	}
+	keys = map[string]bool{}
+	ttl := strconv.Itoa(int(redisKeyTTL.Seconds()))
+	for _, a := range hpa {
+		key := *redisKeyPrefix + hpaKey(a)
+		keys[key] = true
+		cmds = append(cmds,
+			[]string{"DEL", key},
+			append([]string{"HSET", key}, redisHashFields(a, now)...),
+			[]string{"EXPIRE", key, ttl},
+		)
+	}
+	for key := range s.keys {
+		if !keys[key] {
+			cmds = append(cmds, []string{"DEL", key})
+		}
+	}
+	return cmds, changes, keys
+}
+
+func (s *redisSink) Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error {
+	cmds, changes, keys := s.commands(hpa, now)
+	if len(cmds) == 0 {
+		return nil
+	}
+	if s.conn == nil {
+		c, err := dialRedis(redisURL.Get())
+		if err != nil {
+			return err
+		}
+		s.conn = c
+	}
+	cmds = append([][]string{{"MULTI"}}, append(cmds, []string{"EXEC"})...)
+	if _, err := s.conn.Do(cmds...); err != nil {
+		if _, ok := err.(redisError); !ok {
+			s.conn.Close()
+			s.conn = nil
+		}
+		return err
+	}
+	s.tracker.sent(changes)
+	s.keys = keys
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRedisReadReply(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    interface{}
+		wantErr bool
+	}{
+		{"+OK\r\n", "OK", false},
+		{"-ERR wrong type\r\n", nil, true},
+		{":42\r\n", int64(42), false},
+		{"$5\r\nhello\r\n", "hello", false},
+		{"$-1\r\n", nil, false},
+		{"*2\r\n$1\r\na\r\n:1\r\n", []interface{}{"a", int64(1)}, false},
+		{"*1\r\n-ERR x\r\n", nil, true},
+		{"$5\r\nhel", nil, true},
+		{"?\r\n", nil, true},
+		{"\r\n", nil, true},
+	}
+	for _, tt := range tests {
+		c := &redisConn{r: bufio.NewReader(strings.NewReader(tt.in))}
+		got, err := c.readReply()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// readRESPCommand reads a command sent by redisConn.Do.
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	l, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(l[1:]))
+	if err != nil {
+		return nil, err
+	}
+	cmd := []string{}
+	for i := 0; i < n; i++ {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		a, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		cmd = append(cmd, strings.TrimSuffix(a, "\r\n"))
+	}
+	return cmd, nil
+}
+
+// redisServer reads a pipeline for each element of replies, as many
+// commands as it has replies, and answers with them. The commands read are
+// sent to cmds.
+func redisServer(conn net.Conn, replies [][]string, cmds chan<- []string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for _, rs := range replies {
+		for range rs {
+			cmd, err := readRESPCommand(r)
+			if err != nil {
+				return
+			}
+			cmds <- cmd
+		}
+		conn.Write([]byte(strings.Join(rs, "")))
+	}
+}
+
+func TestRedisDo(t *testing.T) {
+	client, server := net.Pipe()
+	cmds := make(chan []string, 2)
+	go redisServer(server, [][]string{{"+OK\r\n", "-ERR no such key\r\n"}}, cmds)
+	c := &redisConn{conn: client, r: bufio.NewReader(client)}
+	got, err := c.Do([]string{"SET", "k", "v"}, []string{"RENAME", "a", "b"})
+	if _, ok := err.(redisError); !ok {
+		t.Fatalf("got error %v, want the error reply", err)
+	}
+	if len(got) != 2 || got[0] != "OK" {
+		t.Fatalf("got replies %v", got)
+	}
+	if cmd := <-cmds; !reflect.DeepEqual(cmd, []string{"SET", "k", "v"}) {
+		t.Fatalf("got command %v", cmd)
+	}
+}
+
+func TestRedisSinkKeepsChanges(t *testing.T) {
+	defer func(ch, prefix string) { *redisChannel, *redisKeyPrefix = ch, prefix }(*redisChannel, *redisKeyPrefix)
+	*redisChannel, *redisKeyPrefix = "hpa", ""
+	client, server := net.Pipe()
+	cmds := make(chan []string, 10)
+	go redisServer(server, [][]string{
+		{"+OK\r\n", "+QUEUED\r\n", "-EXECABORT Transaction discarded\r\n"},
+		{"+OK\r\n", "+QUEUED\r\n", "*1\r\n:1\r\n"},
+	}, cmds)
+	s := &redisSink{conn: &redisConn{conn: client, r: bufio.NewReader(client)}}
+	if err := s.Write(trackerHpa(2), testTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(trackerHpa(3), testTime); err == nil {
+		t.Fatal("want the EXECABORT error")
+	}
+	if len(s.tracker.unsent) != 1 {
+		t.Fatalf("got %d unsent changes, want the failed one", len(s.tracker.unsent))
+	}
+	if err := s.Write(trackerHpa(3), testTime); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.tracker.unsent) != 0 {
+		t.Fatalf("got %d unsent changes after the publish", len(s.tracker.unsent))
+	}
+	published := 0
+	for len(cmds) > 0 {
+		if cmd := <-cmds; cmd[0] == "PUBLISH" {
+			published++
+		}
+	}
+	if published != 2 {
+		t.Fatalf("published %d times, want the change again after the failure", published)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// reloadConfig reads the config file and the files it names, and puts them
+// in use only when all are valid. Collections do not run meanwhile, so they
+// never see a mix of the old and the new configuration.
+func reloadConfig() error {
+	collectMu.Lock()
+	old, err := applyConfig()
+	collectMu.Unlock()
+	if err != nil {
+		return err
+	}
+	// the replaced receivers are not flushed anymore
+	flushReceivers(old, time.Now(), true)
+	if *conditionLogging && *loggingTo == "cwlogs" {
+		return checkLogGroup()
+	}
+	return nil
+}
+
+// applyConfig must be called holding collectMu. Nothing is changed when
+// the new configuration is invalid.
+func applyConfig() ([]namedReceiver, error) {
+	c, err := reloadedConfig(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := parseTenants(*tenantsFile)
+	if err != nil {
+		return nil, err
+	}
+	n, err := parseNotifyConfig(*notifyConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := parseLoggingSchedule(*loggingScheduleFile)
+	if err != nil {
+		return nil, err
+	}
+	cw, err := newCWSession()
+	if err != nil {
+		return nil, err
+	}
+	appliedConfigValue.Store(c)
+	setTenants(ts)
+	old := setNotifyConfig(n)
+	setLoggingWindows(ws)
+	setCWClient(cw)
+	resetOTLPClient()
+	return old, nil
+}
+
+func handleSIGHUP() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		log.Info("SIGHUP received, reloading configuration")
+		if err := reloadConfig(); err != nil {
+			log.Errorln("reload failed:", err)
+			continue
+		}
+		log.Info("configuration reloaded")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setFlagValues sets the flags back to values of flagValues.
+func setFlagValues(values map[string]string) {
+	flag.VisitAll(func(f *flag.Flag) {
+		if v, ok := values[f.Name]; ok && f.Value.String() != v {
+			f.Value.Set(v)
+		}
+	})
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer loadAppliedConfig()
+	defer setFlagValues(flagValues())
+	defer func(cl, cf map[string]bool, pv map[string]string) {
+		commandLineFlags, configFileFlags, parsedFlagValues = cl, cf, pv
+	}(commandLineFlags, configFileFlags, parsedFlagValues)
+	config := filepath.Join(dir, "config.yaml")
+	tenants := filepath.Join(dir, "tenants.yaml")
+	write := func(path, s string) {
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// as on start with the config file
+	write(config, "redactPattern: secret\nnotifyRepeatInterval: 1h\n")
+	write(tenants, "")
+	*configFile, *tenantsFile = config, tenants
+	commandLineFlags = map[string]bool{"config": true, "tenantsFile": true}
+	configFileFlags = map[string]bool{}
+	parsedFlagValues = flagValues()
+	if err := loadConfigFile(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadAppliedConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name    string
+		config  string
+		tenants string
+		err     string
+		// redacted is redact("a secret token") after the reload
+		redacted string
+	}{
+		{"unknown flag", "redactPattern: token\nnoSuchFlag: x\n", "", "unknown flag", "a [REDACTED] token"},
+		{"invalid value", "redactPattern: token\nnotifyRepeatInterval: soon\n", "", "invalid value", "a [REDACTED] token"},
+		{"invalid pattern", "redactPattern: '('\nnotifyRepeatInterval: 1h\n", "", "redactPattern", "a [REDACTED] token"},
+		{"invalid tenants", "redactPattern: token\nnotifyRepeatInterval: 1h\n", "a: {selector: \"=\"}\n", "tenant", "a [REDACTED] token"},
+		{"flag not reloaded", "redactPattern: token\nnotifyRepeatInterval: 2h\n", "", "notifyRepeatInterval", "a [REDACTED] token"},
+		{"flag removed", "redactPattern: token\n", "", "notifyRepeatInterval", "a [REDACTED] token"},
+		{"reloaded", "redactPattern: token\nnotifyRepeatInterval: 1h0m0s\n", "", "", "a secret [REDACTED]"},
+		{"filter removed", "notifyRepeatInterval: 1h\n", "", "", "a secret token"},
+	} {
+		write(config, c.config)
+		write(tenants, c.tenants)
+		err := reloadConfig()
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: got %v, want an error about %s", c.name, err, c.err)
+		}
+		if got := redact("a secret token"); got != c.redacted {
+			t.Errorf("%s: redacted to `%s`, want `%s`", c.name, got, c.redacted)
+		}
+		if *notifyRepeatInterval != time.Hour {
+			t.Errorf("%s: notifyRepeatInterval is %v", c.name, *notifyRepeatInterval)
+		}
+	}
+	if !currentAppliedConfig().fromFile["notifyRepeatInterval"] || currentAppliedConfig().fromFile["redactPattern"] {
+		t.Errorf("sources of the config file are %v", currentAppliedConfig().fromFile)
+	}
+}
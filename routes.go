@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// route sends the findings of the namespaces that match its namespace
+// patterns or whose teamLabel has one of its teams to its receivers.
+type route struct {
+	Namespaces []string `yaml:"namespaces"`
+	Teams      []string `yaml:"teams"`
+	Receivers  []string `yaml:"receivers"`
+}
+
+// routing restricts the receivers named in routes to the findings of their
+// routes. Receivers in no route get the findings of all namespaces.
+type routing struct {
+	TeamLabel string   `yaml:"teamLabel"`
+	Routes    []*route `yaml:"routes"`
+
+	routed map[string]bool
+}
+
+func (rt *routing) compile(receivers map[string]bool) error {
+	rt.routed = map[string]bool{}
+	for i, r := range rt.Routes {
+		if len(r.Receivers) == 0 {
+			return fmt.Errorf("route %d has no receivers", i)
+		}
+		if len(r.Teams) > 0 && rt.TeamLabel == "" {
+			return fmt.Errorf("route %d has teams but there is no teamLabel", i)
+		}
+		for _, p := range r.Namespaces {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid namespace pattern `%s` of route %d: %v", p, i, err)
+			}
+		}
+		for _, name := range r.Receivers {
+			if !receivers[name] {
+				return fmt.Errorf("unknown receiver `%s` of route %d", name, i)
+			}
+			rt.routed[name] = true
+		}
+	}
+	return nil
+}
+
+func (r *route) matches(ns, team string) bool {
+	for _, p := range r.Namespaces {
+		if ok, _ := path.Match(p, ns); ok {
+			return true
+		}
+	}
+	for _, t := range r.Teams {
+		if t == team {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceTeams returns the teamLabel of each namespace.
+func (rt *routing) namespaceTeams() (map[string]string, error) {
+	ret := map[string]string{}
+	if rt.TeamLabel == "" {
+		return ret, nil
+	}
+	l, err := kubeClient.CoreV1().Namespaces().List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range l.Items {
+		ret[ns.ObjectMeta.Name] = ns.ObjectMeta.Labels[rt.TeamLabel]
+	}
+	return ret, nil
+}
+
+// route sets the receivers of each finding to those of the matching
+// routes and the receivers in no route.
+func (rt *routing) route(fs []finding, all []string) ([]finding, error) {
+	if len(rt.Routes) == 0 {
+		return fs, nil
+	}
+	teams, err := rt.namespaceTeams()
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fs {
+		allowed := map[string]bool{}
+		for _, r := range rt.Routes {
+			if r.matches(f.Namespace, teams[f.Namespace]) {
+				for _, name := range r.Receivers {
+					allowed[name] = true
+				}
+			}
+		}
+		names := f.Receivers
+		if names == nil {
+			names = all
+		}
+		rs := []string{}
+		for _, name := range names {
+			if !rt.routed[name] || allowed[name] {
+				rs = append(rs, name)
+			}
+		}
+		fs[i].Receivers = rs
+	}
+	return fs, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var (
+	alertAtMaxDuration              = flag.Duration("alert-at-max-duration", 0, "Notify the receivers of -notifyConfig of HPAs limited by max replicas for this long, without writing rules. Disabled when 0.")
+	alertMetricsUnavailableDuration = flag.Duration("alert-metrics-unavailable-duration", 0, "Notify the receivers of -notifyConfig of HPAs whose ScalingActive is False for this long, without writing rules. Disabled when 0.")
+)
+
+// thresholdRules are the rules of the -alert-*-duration flags.
+func thresholdRules() []*notifyRule {
+	ret := []*notifyRule{}
+	if *alertAtMaxDuration > 0 {
+		ret = append(ret, &notifyRule{
+			Name:      "HPAAtMaxReplicas",
+			Condition: "ScalingLimited",
+			Reason:    "TooManyReplicas",
+			For:       *alertAtMaxDuration,
+			Summary:   "HPA {{ .Namespace }}/{{ .Name }} has been at max replicas for " + alertAtMaxDuration.String() + ".",
+		})
+	}
+	if *alertMetricsUnavailableDuration > 0 {
+		ret = append(ret, &notifyRule{
+			Name:      "HPAMetricsUnavailable",
+			Condition: "ScalingActive",
+			Status:    "False",
+			For:       *alertMetricsUnavailableDuration,
+			Summary:   "HPA {{ .Namespace }}/{{ .Name }} cannot compute metrics: {{ .Labels.cond_reason }} {{ .Labels.cond_message }}",
+		})
+	}
+	return ret
+}
+
+// escalation notifies more receivers of a finding that has been firing for
+// After.
+type escalation struct {
+	After     time.Duration `yaml:"after"`
+	Receivers []string      `yaml:"receivers"`
+}
+
+// notifyRule turns the HPAs that match a detection or a condition for some
+// time into findings for its receivers.
+type notifyRule struct {
+	Name       string        `yaml:"name"`
+	Detection  string        `yaml:"detection"`
+	Condition  string        `yaml:"condition"`
+	Status     string        `yaml:"status"`
+	Reason     string        `yaml:"reason"`
+	For        time.Duration `yaml:"for"`
+	Namespaces []string      `yaml:"namespaces"`
+	Severity   string        `yaml:"severity"`
+	Receivers  []string      `yaml:"receivers"`
+	Summary    string        `yaml:"summary"`
+	// Escalations add receivers when a finding stays unresolved.
+	Escalations []escalation `yaml:"escalations"`
+
+	summary   *template.Template
+	threshold bool
+}
+
+func onlyThresholdRules(rules []*notifyRule) bool {
+	for _, r := range rules {
+		if !r.threshold {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *notifyRule) compile(receivers map[string]bool) error {
+	if r.Name == "" {
+		return fmt.Errorf("rule has no name")
+	}
+	if (r.Detection == "") == (r.Condition == "") {
+		return fmt.Errorf("rule `%s` needs either detection or condition", r.Name)
+	}
+	if r.Detection != "" {
+		known := false
+		for _, d := range detections {
+			if d.Rule == r.Detection {
+				known = true
+				if r.Severity == "" {
+					r.Severity = d.Severity
+				}
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown detection `%s` of rule `%s`", r.Detection, r.Name)
+		}
+	}
+	if r.Status == "" {
+		r.Status = "True"
+	}
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+	for _, p := range r.Namespaces {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern `%s` of rule `%s`: %v", p, r.Name, err)
+		}
+	}
+	names := r.Receivers
+	for _, e := range r.Escalations {
+		if e.After <= 0 || len(e.Receivers) == 0 {
+			return fmt.Errorf("escalations of rule `%s` need after and receivers", r.Name)
+		}
+		names = append(names, e.Receivers...)
+	}
+	for _, name := range names {
+		if !receivers[name] {
+			return fmt.Errorf("unknown receiver `%s` of rule `%s`", name, r.Name)
+		}
+	}
+	summary := r.Summary
+	if summary == "" {
+		if r.Detection != "" {
+			summary = `{{ .Summary }}`
+		} else {
+			summary = `HPA {{ .Namespace }}/{{ .Name }}: ` + r.Condition + ` is ` + r.Status + ` ({{ .Labels.cond_reason }})`
+		}
+	}
+	var err error
+	r.summary, err = parseNotifyTemplate("summary of rule "+r.Name, summary)
+	return err
+}
+
+func (r *notifyRule) matchesNamespace(ns string) bool {
+	if len(r.Namespaces) == 0 {
+		return true
+	}
+	for _, p := range r.Namespaces {
+		if ok, _ := path.Match(p, ns); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the finding of the rule for the HPA if it currently
+// matches, without regard to For.
+func (r *notifyRule) match(a as_v2.HorizontalPodAutoscaler, detected map[string]finding) (finding, bool) {
+	if !r.matchesNamespace(a.ObjectMeta.Namespace) {
+		return finding{}, false
+	}
+	if r.Detection != "" {
+		f, ok := detected[r.Detection+"/"+hpaKey(a)]
+		return f, ok
+	}
+	for _, c := range a.Status.Conditions {
+		if string(c.Type) != r.Condition || string(c.Status) != r.Status {
+			continue
+		}
+		if r.Reason != "" && !strings.EqualFold(c.Reason, r.Reason) {
+			continue
+		}
+		return finding{
+			Namespace: a.ObjectMeta.Namespace,
+			Name:      a.ObjectMeta.Name,
+			UID:       string(a.ObjectMeta.UID),
+			Labels: map[string]string{
+				"cond_status":  string(c.Status),
+				"cond_reason":  c.Reason,
+				"cond_message": redact(c.Message),
+			},
+			HPA: a,
+		}, true
+	}
+	return finding{}, false
+}
+
+// receiversAt returns the receivers of a finding that has been firing for
+// d. nil means all receivers; a rule with escalations only notifies the
+// receivers it names.
+func (r *notifyRule) receiversAt(d time.Duration) []string {
+	if len(r.Escalations) == 0 {
+		return r.Receivers
+	}
+	ret := append([]string{}, r.Receivers...)
+	for _, e := range r.Escalations {
+		if d >= e.After {
+			ret = append(ret, e.Receivers...)
+		}
+	}
+	return ret
+}
+
+// ruleMatchSince remembers since when each rule matches each HPA.
+var ruleMatchSince = map[string]time.Time{}
+
+func evaluateRules(rules []*notifyRule, hpa []as_v2.HorizontalPodAutoscaler, detected []finding, now time.Time) []finding {
+	byKey := map[string]finding{}
+	for _, f := range detected {
+		byKey[f.Key()] = f
+	}
+	since := map[string]time.Time{}
+	ret := []finding{}
+	for _, r := range rules {
+		for _, a := range hpa {
+			f, ok := r.match(a, byKey)
+			if !ok {
+				continue
+			}
+			key := r.Name + "/" + hpaKey(a)
+			s, ok := ruleMatchSince[key]
+			if !ok {
+				s = now
+			}
+			since[key] = s
+			if now.Sub(s) < r.For {
+				continue
+			}
+			f.Rule = r.Name
+			f.Severity = r.Severity
+			f.Status = findingFiring
+			f.StartsAt = s
+			f.Receivers = r.receiversAt(now.Sub(s) - r.For)
+			if summary, err := executeTemplate(r.summary, newNotifyData([]finding{f})); err == nil {
+				f.Summary = summary
+			}
+			ret = append(ret, f)
+		}
+	}
+	ruleMatchSince = since
+	return ret
+}
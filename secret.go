@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// secret is a credential given either as a flag value or as a file, e.g. a
+// mounted Kubernetes Secret. The file is re-read when it changes, so a
+// rotated Secret is picked up without a restart.
+type secret struct {
+	name  string
+	value *string
+	file  *string
+
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	cached  string
+}
+
+// newSecret registers the flag `name` and the flag `nameFile` to read the
+// value from a file, which takes precedence.
+func newSecret(name, usage string) *secret {
+	secretFlags[name] = true
+	return &secret{
+		name:  name,
+		value: flag.String(name, "", usage),
+		file:  flag.String(name+"File", "", "File to read "+name+" from. It is re-read when the file changes."),
+	}
+}
+
+func (s *secret) Get() string {
+	if *s.file == "" {
+		return *s.value
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fi, err := os.Stat(*s.file)
+	if err != nil {
+		log.Errorf("read %sFile: %v", s.name, err)
+		return s.cached
+	}
+	if s.path == *s.file && fi.ModTime().Equal(s.modTime) {
+		return s.cached
+	}
+	b, err := ioutil.ReadFile(*s.file)
+	if err != nil {
+		log.Errorf("read %sFile: %v", s.name, err)
+		return s.cached
+	}
+	s.path = *s.file
+	s.modTime = fi.ModTime()
+	s.cached = strings.TrimSpace(string(b))
+	return s.cached
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var shardIndex = flag.Int("shard-index", 0, "Index of this exporter replica among shard-count replicas.")
+var shardCount = flag.Int("shard-count", 1, "Number of exporter replicas splitting the HPAs. Each replica exports the HPAs whose hash modulo shard-count is shard-index.")
+var shardBy = flag.String("shard-by", defaultShardBy, "What is hashed to assign an HPA to a shard. (namespace or name) namespace keeps the HPAs of a namespace together, which detecting duplicate targets and controller conflicts needs.")
+
+func validateShardFlags() error {
+	if *shardCount < 1 {
+		return fmt.Errorf("invalid value `%d` of flag `shard-count`, it must be 1 or more", *shardCount)
+	}
+	if *shardIndex < 0 || *shardIndex >= *shardCount {
+		return fmt.Errorf("invalid value `%d` of flag `shard-index`, it must be between 0 and shard-count - 1", *shardIndex)
+	}
+	if !(*shardBy == "namespace" || *shardBy == "name") {
+		return fmt.Errorf("invalid value `%s` of flag `shard-by`, specify either `namespace` or `name`", *shardBy)
+	}
+	return nil
+}
+
+func inShard(a as_v2.HorizontalPodAutoscaler) bool {
+	if *shardCount <= 1 {
+		return true
+	}
+	key := a.ObjectMeta.Namespace
+	if *shardBy == "name" {
+		key = hpaKey(a)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()%uint32(*shardCount)) == *shardIndex
+}
+
+func filterShard(hpa []as_v2.HorizontalPodAutoscaler) []as_v2.HorizontalPodAutoscaler {
+	if *shardCount <= 1 {
+		return hpa
+	}
+	ret := make([]as_v2.HorizontalPodAutoscaler, 0, len(hpa)/(*shardCount)+1)
+	for _, a := range hpa {
+		if inShard(a) {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
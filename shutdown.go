@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// shutdownTimeout bounds the shutdown functions, within the 30s Kubernetes
+// waits before SIGKILL by default.
+const shutdownTimeout = 20 * time.Second
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownFuncs []func()
+)
+
+// onShutdown registers f to run when the exporter is stopped, e.g. to flush
+// buffered events. The functions run in the order they were registered.
+func onShutdown(f func()) {
+	shutdownMu.Lock()
+	shutdownFuncs = append(shutdownFuncs, f)
+	shutdownMu.Unlock()
+}
+
+func runShutdown() {
+	shutdownMu.Lock()
+	fs := shutdownFuncs
+	shutdownMu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		for _, f := range fs {
+			f()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		log.Errorf("shutdown did not finish in %s", shutdownTimeout)
+	}
+}
+
+func handleShutdown() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-c
+	log.Infof("%s received, shutting down", sig)
+	runShutdown()
+	os.Exit(0)
+}
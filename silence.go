@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// silence keeps findings of the namespaces, HPAs (namespace/name) and
+// rules matching its patterns from being notified, either between StartsAt
+// and EndsAt or in the windows of Schedule. Empty pattern lists match
+// everything.
+type silence struct {
+	ID         string    `yaml:"id" json:"id"`
+	Comment    string    `yaml:"comment" json:"comment,omitempty"`
+	Namespaces []string  `yaml:"namespaces" json:"namespaces,omitempty"`
+	HPAs       []string  `yaml:"hpas" json:"hpas,omitempty"`
+	Rules      []string  `yaml:"rules" json:"rules,omitempty"`
+	StartsAt   time.Time `yaml:"startsAt" json:"startsAt"`
+	EndsAt     time.Time `yaml:"endsAt" json:"endsAt"`
+
+	Schedule *cronWindow `yaml:"schedule" json:"schedule,omitempty"`
+}
+
+func (s *silence) compile() error {
+	for _, ps := range [][]string{s.Namespaces, s.HPAs, s.Rules} {
+		for _, p := range ps {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid pattern `%s`: %v", p, err)
+			}
+		}
+	}
+	if s.Schedule != nil {
+		return s.Schedule.compile()
+	}
+	if s.EndsAt.IsZero() {
+		return fmt.Errorf("silence needs either endsAt or schedule")
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *silence) Active(now time.Time) bool {
+	if s.Schedule != nil {
+		return s.Schedule.Active(now)
+	}
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+func (s *silence) Matches(f finding) bool {
+	return matchesAny(s.Namespaces, f.Namespace) &&
+		matchesAny(s.HPAs, f.Namespace+"/"+f.Name) &&
+		matchesAny(s.Rules, f.Rule)
+}
+
+var (
+	silencesMu sync.RWMutex
+	// configSilences are read from the notify config, adminSilences are
+	// added by the admin endpoint and kept across reloads.
+	configSilences []*silence
+	adminSilences  = map[string]*silence{}
+)
+
+func setConfigSilences(ss []*silence) {
+	silencesMu.Lock()
+	configSilences = ss
+	silencesMu.Unlock()
+}
+
+// activeSilences returns the silences active at now, dropping ad hoc
+// silences that ended.
+func activeSilences(now time.Time) []*silence {
+	silencesMu.Lock()
+	defer silencesMu.Unlock()
+	active := []*silence{}
+	for _, s := range configSilences {
+		if s.Active(now) {
+			active = append(active, s)
+		}
+	}
+	for id, s := range adminSilences {
+		if !now.Before(s.EndsAt) {
+			delete(adminSilences, id)
+			continue
+		}
+		if s.Active(now) {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+func silenced(active []*silence, f finding) bool {
+	for _, s := range active {
+		if s.Matches(f) {
+			log.Debugf("finding %s is silenced by %s", f.Key(), s.ID)
+			return true
+		}
+	}
+	return false
+}
+
+type silenceRequest struct {
+	Comment    string   `json:"comment"`
+	Namespaces []string `json:"namespaces"`
+	HPAs       []string `json:"hpas"`
+	Rules      []string `json:"rules"`
+	// For is a duration such as 2h from now, or EndsAt a time.
+	For    string    `json:"for"`
+	EndsAt time.Time `json:"endsAt"`
+}
+
+func newSilenceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// silencesHandler lists the silences on GET, adds one on POST and deletes
+// the one of ?id= on DELETE.
+func silencesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		silencesMu.RLock()
+		ss := append([]*silence{}, configSilences...)
+		for _, s := range adminSilences {
+			ss = append(ss, s)
+		}
+		silencesMu.RUnlock()
+		sort.SliceStable(ss, func(i, j int) bool { return ss[i].ID < ss[j].ID })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ss)
+	case http.MethodPost:
+		req := silenceRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		s := &silence{
+			ID:         newSilenceID(),
+			Comment:    req.Comment,
+			Namespaces: req.Namespaces,
+			HPAs:       req.HPAs,
+			Rules:      req.Rules,
+			StartsAt:   now,
+			EndsAt:     req.EndsAt,
+		}
+		if req.For != "" {
+			d, err := time.ParseDuration(req.For)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.EndsAt = now.Add(d)
+		}
+		if err := s.compile(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		silencesMu.Lock()
+		adminSilences[s.ID] = s
+		silencesMu.Unlock()
+		log.Infof("silence %s added until %s: %s", s.ID, s.EndsAt.Format(time.RFC3339), s.Comment)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		silencesMu.Lock()
+		_, ok := adminSilences[id]
+		delete(adminSilences, id)
+		silencesMu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		log.Infof("silence %s deleted", id)
+		w.Write([]byte("deleted\n"))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
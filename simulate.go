@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/buildsville/hpa-exporter/pkg/collector/collectortest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var simulate = flag.Int("simulate", 0, "Collect and expose this many synthetic HPAs without a cluster, print timing and allocation stats, then exit.")
+var simulateRounds = flag.Int("simulateRounds", defaultSimulateRounds, "Collections to average the stats of -simulate over.")
+
+// simulatedNamespaces spreads the synthetic HPAs like a cluster of many
+// teams would.
+const simulatedNamespaces = 50
+
+// syntheticHpa returns the i-th synthetic HPA. Every HPA has a CPU metric
+// and its three conditions; every other one also a Pods metric and every
+// third one an External metric, so the series per HPA vary like in real
+// clusters.
+func syntheticHpa(i int) as_v2.HorizontalPodAutoscaler {
+	min := int32(1 + i%3)
+	max := int32(10 + i%20)
+	current := min + int32(i)%(max-min+1)
+	util := int32(80)
+	currentUtil := int32(40 + i%80)
+	a := as_v2.HorizontalPodAutoscaler{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      fmt.Sprintf("hpa-%d", i),
+			Namespace: fmt.Sprintf("ns-%d", i%simulatedNamespaces),
+		},
+		Spec: as_v2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: as_v2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: fmt.Sprintf("app-%d", i)},
+			MinReplicas:    &min,
+			MaxReplicas:    max,
+			Metrics: []as_v2.MetricSpec{
+				{Type: as_v2.ResourceMetricSourceType, Resource: &as_v2.ResourceMetricSource{Name: core_v1.ResourceCPU, TargetAverageUtilization: &util}},
+			},
+		},
+		Status: as_v2.HorizontalPodAutoscalerStatus{
+			CurrentReplicas: current,
+			DesiredReplicas: current,
+			CurrentMetrics: []as_v2.MetricStatus{
+				{Type: as_v2.ResourceMetricSourceType, Resource: &as_v2.ResourceMetricStatus{Name: core_v1.ResourceCPU, CurrentAverageUtilization: &currentUtil}},
+			},
+			Conditions: []as_v2.HorizontalPodAutoscalerCondition{
+				{Type: as_v2.AbleToScale, Status: core_v1.ConditionTrue, Reason: "ReadyForNewScale", Message: "recommended size matches current size"},
+				{Type: as_v2.ScalingActive, Status: core_v1.ConditionTrue, Reason: "ValidMetricFound", Message: "the HPA was able to successfully calculate a replica count from cpu resource utilization (percentage of request)"},
+				{Type: as_v2.ScalingLimited, Status: core_v1.ConditionFalse, Reason: "DesiredWithinRange", Message: "the desired count is within the acceptable range"},
+			},
+		},
+	}
+	if i%2 == 0 {
+		a.Spec.Metrics = append(a.Spec.Metrics, as_v2.MetricSpec{Type: as_v2.PodsMetricSourceType, Pods: &as_v2.PodsMetricSource{MetricName: "requests_per_second", TargetAverageValue: resource.MustParse("100")}})
+		a.Status.CurrentMetrics = append(a.Status.CurrentMetrics, as_v2.MetricStatus{Type: as_v2.PodsMetricSourceType, Pods: &as_v2.PodsMetricStatus{MetricName: "requests_per_second", CurrentAverageValue: *resource.NewQuantity(int64(i%200), resource.DecimalSI)}})
+	}
+	if i%3 == 0 {
+		a.Spec.Metrics = append(a.Spec.Metrics, as_v2.MetricSpec{Type: as_v2.ExternalMetricSourceType, External: &as_v2.ExternalMetricSource{MetricName: "queue_messages_ready", TargetValue: resource.NewQuantity(30, resource.DecimalSI)}})
+		a.Status.CurrentMetrics = append(a.Status.CurrentMetrics, as_v2.MetricStatus{Type: as_v2.ExternalMetricSourceType, External: &as_v2.ExternalMetricStatus{MetricName: "queue_messages_ready", CurrentValue: *resource.NewQuantity(int64(i%60), resource.DecimalSI)}})
+	}
+	return a
+}
+
+// simulatedChecks need other resources than HPAs, which the fake client
+// does not serve, and are turned off.
+var simulatedChecks = map[string]*bool{
+	"checkTarget":             checkTarget,
+	"crossCheckMetrics":       crossCheckMetrics,
+	"containerMetrics":        containerMetrics,
+	"checkControllerConflict": checkControllerConflict,
+	"checkPDB":                checkPDB,
+	"checkCapacity":           checkCapacity,
+	"checkPendingPods":        checkPendingPods,
+	"checkQuota":              checkQuota,
+}
+
+func runSimulation(n int) error {
+	if *simulateRounds < 1 {
+		return fmt.Errorf("invalid value `%d` of flag `simulateRounds`", *simulateRounds)
+	}
+	for name, v := range simulatedChecks {
+		if *v {
+			fmt.Printf("-%s is ignored in simulation\n", name)
+			*v = false
+		}
+	}
+	hpa := make([]as_v2.HorizontalPodAutoscaler, 0, n)
+	for i := 0; i < n; i++ {
+		hpa = append(hpa, syntheticHpa(i))
+	}
+	kubeClient = collectortest.NewClient(hpa...)
+
+	// the first collection creates the histories and is not measured
+	if err := collectMetrics(); err != nil {
+		return err
+	}
+	var collectTotal, collectMax, exposeTotal time.Duration
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for r := 0; r < *simulateRounds; r++ {
+		start := time.Now()
+		if err := collectMetrics(); err != nil {
+			return err
+		}
+		d := time.Since(start)
+		collectTotal += d
+		if d > collectMax {
+			collectMax = d
+		}
+		start = time.Now()
+		if _, _, err := exposition(); err != nil {
+			return err
+		}
+		exposeTotal += time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+	size, series, err := exposition()
+	if err != nil {
+		return err
+	}
+	rounds := uint64(*simulateRounds)
+	fmt.Printf("HPAs:                  %d\n", n)
+	fmt.Printf("series:                %d\n", series)
+	fmt.Printf("exposition size:       %d bytes\n", size)
+	fmt.Printf("collection:            %s avg, %s max\n", collectTotal/time.Duration(rounds), collectMax)
+	fmt.Printf("exposition:            %s avg\n", exposeTotal/time.Duration(rounds))
+	fmt.Printf("allocated per round:   %d bytes, %d objects\n", (after.TotalAlloc-before.TotalAlloc)/rounds, (after.Mallocs-before.Mallocs)/rounds)
+	fmt.Printf("heap in use:           %d bytes\n", after.HeapInuse)
+	return nil
+}
+
+// exposition renders the registered metrics like a scrape does and
+// returns the size of the text format and the number of series.
+func exposition() (int, int, error) {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return 0, 0, err
+	}
+	b := &bytes.Buffer{}
+	series := 0
+	for _, mf := range mfs {
+		series += len(mf.Metric)
+		if _, err := expfmt.MetricFamilyToText(b, mf); err != nil {
+			return 0, 0, err
+		}
+	}
+	return b.Len(), series, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+// sink receives the HPAs of every collection, e.g. to store them in a
+// database or to push them to a metrics service.
+type sink interface {
+	Name() string
+	Write(hpa []as_v2.HorizontalPodAutoscaler, now time.Time) error
+}
+
+func newSinks() ([]sink, error) {
+	ret := []sink{}
+	if postgresURL.Get() != "" {
+		ret = append(ret, newPostgresSink())
+	}
+	if *bigqueryProject != "" {
+		ret = append(ret, newBigquerySink())
+	}
+	if datadogAPIKey.Get() != "" {
+		ret = append(ret, newDatadogSink())
+	}
+	if newRelicLicenseKey.Get() != "" {
+		ret = append(ret, newNewRelicSink())
+	}
+	if *azureMonitorResourceID != "" {
+		ret = append(ret, newAzureMonitorSink())
+	}
+	if *gcmProject != "" {
+		ret = append(ret, newCloudMonitoringSink())
+	}
+	if *cwMetricNamespace != "" {
+		s, err := newCWMetricsSink()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	if natsURL.Get() != "" {
+		ret = append(ret, &natsSink{})
+	}
+	if redisURL.Get() != "" {
+		ret = append(ret, &redisSink{})
+	}
+	if amqpURL.Get() != "" {
+		s, err := newAMQPSink()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+func writeSinks(sinks []sink, hpa []as_v2.HorizontalPodAutoscaler, now time.Time) {
+	for _, s := range sinks {
+		if err := s.Write(hpa, now); err != nil {
+			log.Errorf("write to %s: %v", s.Name(), err)
+		}
+	}
+}
+
+// metricSample is a value of a gathered per-HPA metric, for sinks which
+// push the exporter's metrics to a metrics service.
+type metricSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// hpaSamples returns the values of the gathered metrics that have an
+// hpa_name label, leaving out the metrics of the exporter itself.
+func hpaSamples() ([]metricSample, error) {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	ret := []metricSample{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			if labelValue(m, "hpa_name") == "" {
+				continue
+			}
+			s := metricSample{Name: mf.GetName(), Labels: map[string]string{}}
+			for _, l := range m.Label {
+				s.Labels[l.GetName()] = l.GetValue()
+			}
+			switch {
+			case m.Gauge != nil:
+				s.Value = m.Gauge.GetValue()
+			case m.Counter != nil:
+				s.Value = m.Counter.GetValue()
+			case m.Untyped != nil:
+				s.Value = m.Untyped.GetValue()
+			default:
+				continue
+			}
+			ret = append(ret, s)
+		}
+	}
+	return ret, nil
+}
+
+// postJSON posts in as JSON with the given headers and fails on a non-2xx
+// response.
+func postJSON(client *http.Client, url string, header http.Header, in interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("POST %s: %s: %s", url, res.Status, bytes.TrimSpace(b))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func runSnapshot(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	output := fs.String("output", "table", "Output format. (table, json or csv)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	hpa, err := getHpaListV2()
+	if err != nil {
+		return err
+	}
+	switch *output {
+	case "table":
+		return writeHpasTable(os.Stdout, hpa)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hpa)
+	case "csv":
+		return writeHpasCSV(os.Stdout, hpa)
+	default:
+		return fmt.Errorf("invalid value `%s` of flag `output`, specify one of `table`, `json` or `csv`", *output)
+	}
+}
+
+func metricKey(m commonMetrics) string {
+	return m.Kind + "/" + m.Name + "/" + m.MetricName
+}
+
+func formatTargets(a as_v2.HorizontalPodAutoscaler) string {
+	current := map[string]commonMetrics{}
+	for _, m := range statusMetrics(a) {
+		current[metricKey(m)] = m
+	}
+	targets := []string{}
+	for _, t := range specMetrics(a) {
+		unit := ""
+		if t.Kind == "Resource" && t.MetricName == "-" {
+			unit = "%"
+		}
+		c, ok := current[metricKey(t)]
+		if !ok {
+			targets = append(targets, fmt.Sprintf("<unknown>/%s%s", formatValue(t.Value), unit))
+			continue
+		}
+		s := fmt.Sprintf("%s%s/%s%s", formatValue(c.Value), unit, formatValue(t.Value), unit)
+		if t.Value != 0 {
+			s += fmt.Sprintf(" (%.2f)", c.Value/t.Value)
+		}
+		targets = append(targets, s)
+	}
+	if len(targets) == 0 {
+		return "<none>"
+	}
+	return strings.Join(targets, ", ")
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatConditionReasons(conds []as_v2.HorizontalPodAutoscalerCondition) string {
+	reasons := []string{}
+	for _, c := range conds {
+		r := c.Reason
+		if (c.Type == as_v2.ScalingLimited) == (c.Status == core_v1.ConditionFalse) {
+			reasons = append(reasons, r)
+		} else {
+			reasons = append(reasons, "!"+r)
+		}
+	}
+	if len(reasons) == 0 {
+		return "<none>"
+	}
+	return strings.Join(reasons, ",")
+}
+
+func writeHpasTable(w io.Writer, hpa []as_v2.HorizontalPodAutoscaler) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tREFERENCE\tTARGETS\tMINPODS\tMAXPODS\tREPLICAS\tDESIRED\tCONDITIONS")
+	for _, a := range hpa {
+		min := "<unset>"
+		if a.Spec.MinReplicas != nil {
+			min = strconv.Itoa(int(*a.Spec.MinReplicas))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s/%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			a.ObjectMeta.Namespace,
+			a.ObjectMeta.Name,
+			a.Spec.ScaleTargetRef.Kind,
+			a.Spec.ScaleTargetRef.Name,
+			formatTargets(a),
+			min,
+			a.Spec.MaxReplicas,
+			a.Status.CurrentReplicas,
+			a.Status.DesiredReplicas,
+			formatConditionReasons(a.Status.Conditions),
+		)
+	}
+	return tw.Flush()
+}
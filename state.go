@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var stateFile = flag.String("stateFile", "", "File to save counters and the last logged conditions to, so they survive restarts. Nothing is saved when empty.")
+var conditionLogChangesOnly = flag.Bool("conditionLogChangesOnly", defaultConditionLogChangesOnly, "Log the conditions of an HPA only when they changed since they were last logged.")
+
+// persistedCounters are the counters restored from stateFile on start.
+var persistedCounters = map[string]*prometheus.CounterVec{
+	"hpa_direction_changes_total":            hpaDirectionChanges,
+	"hpa_time_at_max_replicas_seconds_total": hpaTimeAtMax,
+	"hpa_metric_fetch_failures_total":        hpaMetricFetchFailures,
+	"hpa_events_total":                       hpaEventsTotal,
+}
+
+type counterState struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+type exporterState struct {
+	SavedAt    time.Time         `json:"savedAt"`
+	Counters   []counterState    `json:"counters"`
+	Conditions map[string]string `json:"conditions"`
+}
+
+var (
+	loggedConditionsMu sync.Mutex
+	loggedConditions   = map[string]string{}
+)
+
+// changedConditions returns the HPAs whose conditions differ from what was
+// last logged, or all of them unless conditionLogChangesOnly is set.
+func changedConditions(hpa []as_v2.HorizontalPodAutoscaler) []as_v2.HorizontalPodAutoscaler {
+	if !*conditionLogChangesOnly {
+		return hpa
+	}
+	loggedConditionsMu.Lock()
+	defer loggedConditionsMu.Unlock()
+	ret := []as_v2.HorizontalPodAutoscaler{}
+	for _, a := range hpa {
+		if loggedConditions[hpaKey(a)] != hpaConditionJsonString(a) {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
+
+// conditionChange is a condition whose status, reason, message or
+// transition time changed since it was last logged. Previous is nil for
+// new conditions and Current for removed ones.
+type conditionChange struct {
+	Type     as_v2.HorizontalPodAutoscalerConditionType `json:"type"`
+	Previous *as_v2.HorizontalPodAutoscalerCondition    `json:"previous,omitempty"`
+	Current  *as_v2.HorizontalPodAutoscalerCondition    `json:"current,omitempty"`
+}
+
+// conditionChanges compares the conditions of the HPA with those last
+// logged, both redacted.
+func conditionChanges(a as_v2.HorizontalPodAutoscaler) []conditionChange {
+	loggedConditionsMu.Lock()
+	logged := loggedConditions[hpaKey(a)]
+	loggedConditionsMu.Unlock()
+	prev := conditions{}
+	if logged != "" {
+		if err := json.Unmarshal([]byte(logged), &prev); err != nil {
+			log.Debugf("last logged conditions of %s: %v", hpaKey(a), err)
+		}
+	}
+	previous := map[as_v2.HorizontalPodAutoscalerConditionType]as_v2.HorizontalPodAutoscalerCondition{}
+	for _, c := range prev.Conditions {
+		previous[c.Type] = c
+	}
+	ret := []conditionChange{}
+	for _, c := range redactConditions(a.Status.Conditions) {
+		c := c
+		p, ok := previous[c.Type]
+		delete(previous, c.Type)
+		if !ok {
+			ret = append(ret, conditionChange{Type: c.Type, Current: &c})
+			continue
+		}
+		if p.Status != c.Status || p.Reason != c.Reason || p.Message != c.Message || !p.LastTransitionTime.Equal(&c.LastTransitionTime) {
+			ret = append(ret, conditionChange{Type: c.Type, Previous: &p, Current: &c})
+		}
+	}
+	for _, c := range prev.Conditions {
+		if _, ok := previous[c.Type]; ok {
+			c := c
+			ret = append(ret, conditionChange{Type: c.Type, Previous: &c})
+		}
+	}
+	return ret
+}
+
+// markConditionsLogged records the logged conditions. hpa is the full list,
+// so HPAs that are gone are forgotten.
+func markConditionsLogged(hpa []as_v2.HorizontalPodAutoscaler) {
+	loggedConditionsMu.Lock()
+	defer loggedConditionsMu.Unlock()
+	m := map[string]string{}
+	for _, a := range hpa {
+		m[hpaKey(a)] = hpaConditionJsonString(a)
+	}
+	loggedConditions = m
+}
+
+func currentState() (exporterState, error) {
+	s := exporterState{SavedAt: time.Now(), Conditions: map[string]string{}}
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return s, err
+	}
+	for _, mf := range mfs {
+		if _, ok := persistedCounters[mf.GetName()]; !ok {
+			continue
+		}
+		for _, m := range mf.Metric {
+			l := map[string]string{}
+			for _, lp := range m.Label {
+				l[lp.GetName()] = lp.GetValue()
+			}
+			s.Counters = append(s.Counters, counterState{mf.GetName(), l, m.GetCounter().GetValue()})
+		}
+	}
+	loggedConditionsMu.Lock()
+	for k, v := range loggedConditions {
+		s.Conditions[k] = v
+	}
+	loggedConditionsMu.Unlock()
+	return s, nil
+}
+
+func saveState(path string) error {
+	if path == "" {
+		return nil
+	}
+	s, err := currentState()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadState(path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s := exporterState{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	for _, c := range s.Counters {
+		v, ok := persistedCounters[c.Name]
+		if !ok {
+			continue
+		}
+		m, err := v.GetMetricWith(c.Labels)
+		if _, ok := c.Labels["api_version"]; err != nil && !ok && c.Labels["hpa_name"] != "" {
+			// saved before label api_version, when only autoscaling/v2beta1
+			// was read
+			c.Labels["api_version"] = as_v2.SchemeGroupVersion.Version
+			m, err = v.GetMetricWith(c.Labels)
+		}
+		if err != nil {
+			// saved by a version with other labels
+			log.Errorf("restore %s %v: %v", c.Name, c.Labels, err)
+			continue
+		}
+		m.Add(c.Value)
+	}
+	loggedConditionsMu.Lock()
+	if s.Conditions != nil {
+		loggedConditions = s.Conditions
+	}
+	loggedConditionsMu.Unlock()
+	log.Infof("restored state saved at %s", s.SavedAt)
+	return nil
+}
+
+// saveStateOnShutdown is registered with onShutdown.
+func saveStateOnShutdown() {
+	if err := saveState(*stateFile); err != nil {
+		log.Errorln("save state:", err)
+		return
+	}
+	log.Info("state saved")
+}
+
+// persistState saves the state every interval.
+func persistState(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := saveState(*stateFile); err != nil {
+			log.Errorln("save state:", err)
+		}
+	}
+}
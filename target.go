@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	as_v1 "k8s.io/api/autoscaling/v1"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var checkTarget = flag.Bool("checkTarget", defaultCheckTarget, "Read the scale target (Deployment, StatefulSet, ReplicaSet, argo Rollout or OpenShift DeploymentConfig, or the scale subresource of other kinds) of each HPA for target based metrics. Needs `get` on those resources.")
+
+// scaleTarget is the part of an HPA's scale target the detectors look at.
+type scaleTarget struct {
+	SpecReplicas   int32
+	StatusReplicas int32
+	ReadyReplicas  int32
+	Selector       string
+	// PodLabels are the labels of the pod template.
+	PodLabels    map[string]string
+	PodResources podResources
+	// ContainerRequests are the requests of each container of the pod
+	// template.
+	ContainerRequests map[string]core_v1.ResourceList
+	// ReplicaFailure is the message of the ReplicaFailure condition of a
+	// Deployment or ReplicaSet, set when pods fail to be created.
+	ReplicaFailure string
+	// Subresource is set when the target was read through its scale
+	// subresource, which has neither pod template nor ready replicas.
+	Subresource bool
+}
+
+// podResources are the requests and limits of a pod template summed over
+// its containers, in cores and bytes.
+type podResources struct {
+	CPURequest    float64
+	CPULimit      float64
+	MemoryRequest float64
+	MemoryLimit   float64
+}
+
+func podTemplateResources(spec core_v1.PodSpec) podResources {
+	r := podResources{}
+	for _, c := range spec.Containers {
+		r.CPURequest += float64(c.Resources.Requests.Cpu().MilliValue()) / 1000
+		r.CPULimit += float64(c.Resources.Limits.Cpu().MilliValue()) / 1000
+		r.MemoryRequest += float64(c.Resources.Requests.Memory().Value())
+		r.MemoryLimit += float64(c.Resources.Limits.Memory().Value())
+	}
+	return r
+}
+
+func containerRequests(spec core_v1.PodSpec) map[string]core_v1.ResourceList {
+	ret := map[string]core_v1.ResourceList{}
+	for _, c := range spec.Containers {
+		ret[c.Name] = c.Resources.Requests
+	}
+	return ret
+}
+
+type scaleTargetResult struct {
+	target *scaleTarget
+	err    error
+}
+
+// cycleTargets caches scale targets within a collection so that each
+// target is read at most once. It is only accessed while holding collectMu.
+var cycleTargets = map[string]scaleTargetResult{}
+
+func resetScaleTargetCache() {
+	cycleTargets = map[string]scaleTargetResult{}
+}
+
+func cachedScaleTarget(a as_v2.HorizontalPodAutoscaler) (*scaleTarget, error) {
+	k := a.ObjectMeta.Namespace + "/" + a.Spec.ScaleTargetRef.Kind + "/" + a.Spec.ScaleTargetRef.Name
+	if r, ok := cycleTargets[k]; ok {
+		return r.target, r.err
+	}
+	t, err := getScaleTarget(a)
+	cycleTargets[k] = scaleTargetResult{t, err}
+	return t, err
+}
+
+type unsupportedTargetError struct {
+	Kind string
+}
+
+func (e unsupportedTargetError) Error() string {
+	return fmt.Sprintf("unsupported scale target kind `%s`", e.Kind)
+}
+
+func getScaleTarget(a as_v2.HorizontalPodAutoscaler) (*scaleTarget, error) {
+	ns := a.ObjectMeta.Namespace
+	name := a.Spec.ScaleTargetRef.Name
+	switch a.Spec.ScaleTargetRef.Kind {
+	case "Deployment":
+		d, err := kubeClient.AppsV1().Deployments(ns).Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		t, err := newScaleTarget(d.Spec.Replicas, d.Spec.Selector, d.Spec.Template, d.Status.Replicas, d.Status.ReadyReplicas)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range d.Status.Conditions {
+			if c.Type == apps_v1.DeploymentReplicaFailure && c.Status == core_v1.ConditionTrue {
+				t.ReplicaFailure = c.Message
+			}
+		}
+		return t, nil
+	case "StatefulSet":
+		s, err := kubeClient.AppsV1().StatefulSets(ns).Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return newScaleTarget(s.Spec.Replicas, s.Spec.Selector, s.Spec.Template, s.Status.Replicas, s.Status.ReadyReplicas)
+	case "ReplicaSet":
+		r, err := kubeClient.AppsV1().ReplicaSets(ns).Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		t, err := newScaleTarget(r.Spec.Replicas, r.Spec.Selector, r.Spec.Template, r.Status.Replicas, r.Status.ReadyReplicas)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range r.Status.Conditions {
+			if c.Type == apps_v1.ReplicaSetReplicaFailure && c.Status == core_v1.ConditionTrue {
+				t.ReplicaFailure = c.Message
+			}
+		}
+		return t, nil
+	case "Rollout":
+		w, err := getWorkload("/apis/argoproj.io/v1alpha1", ns, "rollouts", name)
+		if err != nil {
+			return nil, err
+		}
+		if ref := w.Spec.WorkloadRef; ref != nil && ref.Kind == "Deployment" {
+			// the pod template is in the referenced Deployment
+			d, err := kubeClient.AppsV1().Deployments(ns).Get(ref.Name, meta_v1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			w.Spec.Template = d.Spec.Template
+			if w.Spec.Selector == nil {
+				w.Spec.Selector = d.Spec.Selector
+			}
+		}
+		return newScaleTarget(w.Spec.Replicas, w.Spec.Selector, w.Spec.Template, w.Status.Replicas, w.Status.ReadyReplicas)
+	case "DeploymentConfig":
+		c, err := getDeploymentConfig(ns, name)
+		if err != nil {
+			return nil, err
+		}
+		pod := core_v1.PodTemplateSpec{}
+		if c.Spec.Template != nil {
+			pod = *c.Spec.Template
+		}
+		return newScaleTarget(&c.Spec.Replicas, &meta_v1.LabelSelector{MatchLabels: c.Spec.Selector}, pod, c.Status.Replicas, c.Status.ReadyReplicas)
+	default:
+		return getScaleSubresource(a)
+	}
+}
+
+// scaleResourceMissTTL is how long a kind without a scale subresource is
+// remembered, so a CRD installed or given one later is picked up.
+const scaleResourceMissTTL = 10 * time.Minute
+
+type scaleResourceEntry struct {
+	resource string
+	expires  time.Time
+}
+
+// scaleResources caches the resource with a scale subresource of each
+// apiVersion/kind, "" until expires when there is none. It is only accessed
+// while holding collectMu.
+var scaleResources = map[string]scaleResourceEntry{}
+
+func scaleResource(apiVersion, kind string) (string, error) {
+	k := apiVersion + "/" + kind
+	if e, ok := scaleResources[k]; ok && (e.resource != "" || time.Now().Before(e.expires)) {
+		return e.resource, nil
+	}
+	l, err := kubeClient.Discovery().ServerResourcesForGroupVersion(apiVersion)
+	if err != nil {
+		return "", err
+	}
+	names := map[string]bool{}
+	for _, r := range l.APIResources {
+		names[r.Name] = true
+	}
+	ret := ""
+	for _, r := range l.APIResources {
+		if r.Kind == kind && !strings.Contains(r.Name, "/") && names[r.Name+"/scale"] {
+			ret = r.Name
+		}
+	}
+	scaleResources[k] = scaleResourceEntry{resource: ret, expires: time.Now().Add(scaleResourceMissTTL)}
+	return ret, nil
+}
+
+// getScaleSubresource reads the scale subresource of any other kind, such
+// as custom resources. It has no pod template and no ready replicas.
+func getScaleSubresource(a as_v2.HorizontalPodAutoscaler) (*scaleTarget, error) {
+	ref := a.Spec.ScaleTargetRef
+	if ref.APIVersion == "" {
+		return nil, unsupportedTargetError{ref.Kind}
+	}
+	resource, err := scaleResource(ref.APIVersion, ref.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if resource == "" {
+		return nil, unsupportedTargetError{ref.Kind}
+	}
+	prefix := "/apis/" + ref.APIVersion
+	if !strings.Contains(ref.APIVersion, "/") {
+		prefix = "/api/" + ref.APIVersion
+	}
+	b, err := kubeClient.Discovery().RESTClient().Get().AbsPath(prefix, "namespaces", a.ObjectMeta.Namespace, resource, ref.Name, "scale").DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	s := &as_v1.Scale{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return &scaleTarget{
+		SpecReplicas:   s.Spec.Replicas,
+		StatusReplicas: s.Status.Replicas,
+		Selector:       s.Status.Selector,
+		Subresource:    true,
+	}, nil
+}
+
+func newScaleTarget(replicas *int32, selector *meta_v1.LabelSelector, pod core_v1.PodTemplateSpec, statusReplicas, readyReplicas int32) (*scaleTarget, error) {
+	sel, err := selectorString(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &scaleTarget{
+		SpecReplicas:      replicasOrDefault(replicas),
+		StatusReplicas:    statusReplicas,
+		ReadyReplicas:     readyReplicas,
+		Selector:          sel,
+		PodLabels:         pod.ObjectMeta.Labels,
+		PodResources:      podTemplateResources(pod.Spec),
+		ContainerRequests: containerRequests(pod.Spec),
+	}, nil
+}
+
+// workload is the common shape of workload resources that are not in the
+// typed client, such as argo Rollouts.
+type workload struct {
+	Spec struct {
+		Replicas *int32                  `json:"replicas"`
+		Selector *meta_v1.LabelSelector  `json:"selector"`
+		Template core_v1.PodTemplateSpec `json:"template"`
+		// WorkloadRef is set on Rollouts that take the pod template from
+		// a Deployment.
+		WorkloadRef *struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"workloadRef"`
+	} `json:"spec"`
+	Status struct {
+		Replicas      int32 `json:"replicas"`
+		ReadyReplicas int32 `json:"readyReplicas"`
+	} `json:"status"`
+}
+
+func getWorkload(groupVersionPath, ns, resource, name string) (*workload, error) {
+	b, err := kubeClient.Discovery().RESTClient().Get().AbsPath(groupVersionPath, "namespaces", ns, resource, name).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	w := &workload{}
+	if err := json.Unmarshal(b, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// deploymentConfig is the part of an OpenShift DeploymentConfig that is
+// read, whose selector is a plain label map.
+type deploymentConfig struct {
+	Spec struct {
+		Replicas int32                    `json:"replicas"`
+		Selector map[string]string        `json:"selector"`
+		Template *core_v1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+	Status struct {
+		Replicas      int32 `json:"replicas"`
+		ReadyReplicas int32 `json:"readyReplicas"`
+	} `json:"status"`
+}
+
+func getDeploymentConfig(ns, name string) (*deploymentConfig, error) {
+	b, err := kubeClient.Discovery().RESTClient().Get().AbsPath("/apis/apps.openshift.io/v1", "namespaces", ns, "deploymentconfigs", name).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	c := &deploymentConfig{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func replicasOrDefault(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+func selectorString(ls *meta_v1.LabelSelector) (string, error) {
+	sel, err := meta_v1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return "", err
+	}
+	return sel.String(), nil
+}
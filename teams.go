@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+)
+
+// teamsConfig posts findings as adaptive cards to Microsoft Teams incoming
+// webhooks, to the webhook of the first namespace pattern matching the HPA.
+type teamsConfig struct {
+	URL        string            `yaml:"url"`
+	Namespaces map[string]string `yaml:"namespaces"`
+	Timeout    time.Duration     `yaml:"timeout"`
+}
+
+type teamsReceiver struct {
+	config   *teamsConfig
+	client   *http.Client
+	template *messageTemplate
+}
+
+func newTeamsReceiver(c *teamsConfig, t *messageTemplate) (*teamsReceiver, error) {
+	if c.URL == "" && len(c.Namespaces) == 0 {
+		return nil, fmt.Errorf("teams has neither url nor namespaces")
+	}
+	for p := range c.Namespaces {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern `%s`: %v", p, err)
+		}
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &teamsReceiver{config: c, client: &http.Client{Timeout: timeout}, template: t}, nil
+}
+
+// namespaceURL returns the URL of the first pattern in urls, in sorted
+// order, matching namespace, or def.
+func namespaceURL(def string, urls map[string]string, namespace string) string {
+	patterns := make([]string, 0, len(urls))
+	for p := range urls {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, namespace); ok {
+			return urls[p]
+		}
+	}
+	return def
+}
+
+// findingFacts are the details of a finding shown by chat receivers.
+func findingFacts(f finding) [][2]string {
+	a := f.HPA
+	facts := [][2]string{
+		{"HPA", hpaKey(a)},
+		{"Target", a.Spec.ScaleTargetRef.Kind + "/" + a.Spec.ScaleTargetRef.Name},
+		{"Severity", f.Severity},
+		{"Replicas", fmt.Sprintf("%d current, %d desired (%d-%d)", a.Status.CurrentReplicas, a.Status.DesiredReplicas, minReplicas(a), a.Spec.MaxReplicas)},
+		{"Since", f.StartsAt.Format(time.RFC3339)},
+	}
+	for _, c := range a.Status.Conditions {
+		facts = append(facts, [2]string{string(c.Type), fmt.Sprintf("%s (%s)", c.Status, c.Reason)})
+	}
+	return facts
+}
+
+func (r *teamsReceiver) card(f finding) (map[string]interface{}, error) {
+	title, body, fields, err := r.template.message(f)
+	if err != nil {
+		return nil, err
+	}
+	color := "warning"
+	switch {
+	case f.Status == findingResolved:
+		color = "good"
+	case f.Severity == "critical":
+		color = "attention"
+	}
+	facts := []map[string]string{}
+	for _, kv := range fields {
+		facts = append(facts, map[string]string{"title": kv[0], "value": kv[1]})
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []map[string]interface{}{
+					{"type": "TextBlock", "size": "Medium", "weight": "Bolder", "color": color, "text": title},
+					{"type": "TextBlock", "wrap": true, "text": body},
+					{"type": "FactSet", "facts": facts},
+				},
+			},
+		}},
+	}, nil
+}
+
+func (r *teamsReceiver) Notify(fs []finding) error {
+	for _, f := range fs {
+		url := namespaceURL(r.config.URL, r.config.Namespaces, f.Namespace)
+		if url == "" {
+			continue
+		}
+		card, err := r.card(f)
+		if err != nil {
+			return err
+		}
+		if err := postJSON(r.client, url, nil, card); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var tenantsFile = flag.String("tenantsFile", "", "YAML file of tenants, each served at <metrics-path>/<tenant> with only the HPAs of its namespaces or matching its label selector.")
+
+type tenant struct {
+	Namespaces []string `yaml:"namespaces"`
+	Selector   string   `yaml:"selector"`
+
+	selector labels.Selector
+}
+
+var (
+	tenantsMu sync.RWMutex
+	tenants   = map[string]*tenant{}
+)
+
+func loadTenants(path string) error {
+	ts, err := parseTenants(path)
+	if err != nil {
+		return err
+	}
+	setTenants(ts)
+	return nil
+}
+
+func parseTenants(path string) (map[string]*tenant, error) {
+	ts := map[string]*tenant{}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(b, &ts); err != nil {
+			return nil, fmt.Errorf("parse tenants file %s: %v", path, err)
+		}
+	}
+	for name, t := range ts {
+		if t.Selector == "" {
+			continue
+		}
+		sel, err := labels.Parse(t.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of tenant `%s`: %v", name, err)
+		}
+		t.selector = sel
+	}
+	return ts, nil
+}
+
+func setTenants(ts map[string]*tenant) {
+	tenantsMu.Lock()
+	tenants = ts
+	tenantsMu.Unlock()
+}
+
+func tenantOf(name string) (*tenant, bool) {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	t, ok := tenants[name]
+	return t, ok
+}
+
+// hpas returns the namespace/name of the cached HPAs of the tenant.
+func (t *tenant) hpas() map[string]bool {
+	namespaces := map[string]bool{}
+	for _, ns := range t.Namespaces {
+		namespaces[ns] = true
+	}
+	ret := map[string]bool{}
+	for _, a := range cachedHpaList() {
+		if namespaces[a.ObjectMeta.Namespace] || (t.selector != nil && t.selector.Matches(labels.Set(a.ObjectMeta.Labels))) {
+			ret[hpaKey(a)] = true
+		}
+	}
+	return ret
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// gatherer gathers the metrics of the tenant's HPAs. Metrics of the
+// exporter itself, which have no hpa_name label, are left out.
+func (t *tenant) gatherer() prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			return nil, err
+		}
+		hpas := t.hpas()
+		ret := []*dto.MetricFamily{}
+		for _, mf := range mfs {
+			ms := []*dto.Metric{}
+			for _, m := range mf.Metric {
+				if hpas[labelValue(m, "hpa_namespace")+"/"+labelValue(m, "hpa_name")] {
+					ms = append(ms, m)
+				}
+			}
+			if len(ms) > 0 {
+				mf.Metric = ms
+				ret = append(ret, mf)
+			}
+		}
+		return ret, nil
+	})
+}
+
+func tenantMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := tenantOf(strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(*metricsPath, "/")+"/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	promhttp.HandlerFor(t.gatherer(), promhttp.HandlerOpts{
+		DisableCompression: !*metricsGzip,
+	}).ServeHTTP(w, r)
+}
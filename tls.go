@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const unixAddrPrefix = "unix://"
+
+var tlsCertFile = flag.String("tlsCertFile", "", "Certificate file to serve HTTPS with. HTTP is served when empty.")
+var tlsKeyFile = flag.String("tlsKeyFile", "", "Private key file of tlsCertFile.")
+var tlsClientCAFile = flag.String("tlsClientCAFile", "", "CA file to verify client certificates with. Clients without a valid certificate are rejected when set.")
+var tlsMinVersion = flag.String("tlsMinVersion", defaultTLSMinVersion, "Minimum TLS version accepted by the HTTPS listener. (1.0, 1.1, 1.2 or 1.3)")
+var tlsCipherSuites = flag.String("tlsCipherSuites", "", "Comma separated cipher suites accepted by the HTTPS listener for TLS 1.2 and below, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Go's secure defaults are used when empty.")
+var tlsClientAllowedCNs = flag.String("tlsClientAllowedCNs", "", "Comma separated common names of client certificates allowed to connect. Any verified client is allowed when empty.")
+
+func validateTLSFlags() error {
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		return fmt.Errorf("flags `tlsCertFile` and `tlsKeyFile` must be set together")
+	}
+	if *tlsClientCAFile != "" && *tlsCertFile == "" {
+		return fmt.Errorf("flag `tlsClientCAFile` needs `tlsCertFile` and `tlsKeyFile`")
+	}
+	if *tlsClientAllowedCNs != "" && *tlsClientCAFile == "" {
+		return fmt.Errorf("flag `tlsClientAllowedCNs` needs `tlsClientCAFile`")
+	}
+	if _, ok := tlsVersions[*tlsMinVersion]; !ok {
+		return fmt.Errorf("invalid value `%s` of flag `tlsMinVersion`, specify one of 1.0, 1.1, 1.2 or 1.3", *tlsMinVersion)
+	}
+	_, err := cipherSuiteIDs(*tlsCipherSuites)
+	return err
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func cipherSuiteIDs(names string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, c := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[c.Name] = c.ID
+	}
+	var ret []uint16
+	for _, n := range splitList(names) {
+		id, ok := known[n]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite `%s` in flag `tlsCipherSuites`", n)
+		}
+		ret = append(ret, id)
+	}
+	return ret, nil
+}
+
+func splitList(s string) []string {
+	ret := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+func serverTLSConfig() (*tls.Config, error) {
+	suites, err := cipherSuiteIDs(*tlsCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Config{
+		MinVersion:   tlsVersions[*tlsMinVersion],
+		CipherSuites: suites,
+	}
+	if *tlsClientCAFile == "" {
+		return c, nil
+	}
+	pem, err := ioutil.ReadFile(*tlsClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", *tlsClientCAFile)
+	}
+	c.ClientCAs = pool
+	c.ClientAuth = tls.RequireAndVerifyClientCert
+	if cns := splitList(*tlsClientAllowedCNs); len(cns) > 0 {
+		allowed := map[string]bool{}
+		for _, cn := range cns {
+			allowed[cn] = true
+		}
+		c.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			for _, chain := range chains {
+				if len(chain) > 0 && allowed[chain[0].Subject.CommonName] {
+					return nil
+				}
+			}
+			return errors.New("client certificate common name is not allowed")
+		}
+	}
+	return c, nil
+}
+
+func listenAndServe() error {
+	var h http.Handler = http.DefaultServeMux
+	if *authMode == "kubernetes" {
+		h = withKubernetesAuth(h)
+	}
+	if *auditLog {
+		h = withAudit(h)
+	}
+	s := &http.Server{Addr: *addr, Handler: h}
+	l, err := listen(*addr)
+	if err != nil {
+		return err
+	}
+	if *tlsCertFile == "" {
+		return s.Serve(l)
+	}
+	c, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	s.TLSConfig = c
+	return s.ServeTLS(l, *tlsCertFile, *tlsKeyFile)
+}
+
+// listen listens on a unix domain socket when the address is
+// unix:///path/to.sock, and on TCP otherwise.
+func listen(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, unixAddrPrefix) {
+		return net.Listen("tcp", address)
+	}
+	path := strings.TrimPrefix(address, unixAddrPrefix)
+	// remove the socket left by a previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// clientTLS is the TLS configuration of an outbound backend, set with the
+// flags <prefix>CAFile, <prefix>CertFile, <prefix>KeyFile and
+// <prefix>InsecureSkipVerify.
+type clientTLS struct {
+	prefix             string
+	caFile             *string
+	certFile           *string
+	keyFile            *string
+	insecureSkipVerify *bool
+}
+
+func newClientTLS(prefix, backend string) *clientTLS {
+	return &clientTLS{
+		prefix:             prefix,
+		caFile:             flag.String(prefix+"CAFile", "", "CA file to verify the "+backend+" server certificate with. The system roots are used when empty."),
+		certFile:           flag.String(prefix+"CertFile", "", "Client certificate file presented to "+backend+"."),
+		keyFile:            flag.String(prefix+"KeyFile", "", "Private key file of "+prefix+"CertFile."),
+		insecureSkipVerify: flag.Bool(prefix+"InsecureSkipVerify", false, "Do not verify the "+backend+" server certificate."),
+	}
+}
+
+func (c *clientTLS) Config() (*tls.Config, error) {
+	conf := &tls.Config{InsecureSkipVerify: *c.insecureSkipVerify}
+	if *c.caFile != "" {
+		pem, err := ioutil.ReadFile(*c.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", *c.caFile)
+		}
+		conf.RootCAs = pool
+	}
+	if (*c.certFile == "") != (*c.keyFile == "") {
+		return nil, fmt.Errorf("flags `%sCertFile` and `%sKeyFile` must be set together", c.prefix, c.prefix)
+	}
+	if *c.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*c.certFile, *c.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// HTTPClient returns a client for the backend. The files are read on every
+// call, so backends create their client when the configuration is loaded.
+func (c *clientTLS) HTTPClient(timeout time.Duration) (*http.Client, error) {
+	conf, err := c.Config()
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = conf
+	return &http.Client{Transport: t, Timeout: timeout}, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/prometheus/common/log"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+)
+
+var snapshotUploadURL = flag.String("snapshotUploadURL", "", "s3://bucket/prefix or gs://bucket/prefix to upload HPA snapshots to every snapshotUploadInterval. Snapshots are not uploaded when empty.")
+var snapshotUploadInterval = flag.Duration("snapshotUploadInterval", defaultSnapshotUploadInterval, "Interval to upload HPA snapshots.")
+var snapshotGCSAccessKey = newSecret("snapshotGCSAccessKey", "HMAC access key of the GCS bucket in snapshotUploadURL.")
+var snapshotGCSSecret = newSecret("snapshotGCSSecret", "HMAC secret of snapshotGCSAccessKey.")
+
+const gcsEndpoint = "https://storage.googleapis.com"
+
+func validateSnapshotUploadFlags() error {
+	if *snapshotUploadURL == "" {
+		return nil
+	}
+	u, err := url.Parse(*snapshotUploadURL)
+	if err != nil || !(u.Scheme == "s3" || u.Scheme == "gs") || u.Host == "" {
+		return fmt.Errorf("invalid value `%s` of flag `snapshotUploadURL`, specify s3://bucket/prefix or gs://bucket/prefix", *snapshotUploadURL)
+	}
+	return nil
+}
+
+// writeSnapshotParquet writes the columns of /api/v1/hpas.csv with the
+// snapshot time and the estimated demand as Parquet. A missing
+// minReplicas is written as its default of 1.
+func writeSnapshotParquet(buf *bytes.Buffer, hpa []as_v2.HorizontalPodAutoscaler, now time.Time) {
+	col := func(name string, physical, converted int32) *parquetColumn {
+		return &parquetColumn{name: name, physical: physical, converted: converted}
+	}
+	str := func(name string) *parquetColumn {
+		return col(name, parquetByteArray, parquetUTF8)
+	}
+	i32 := func(name string) *parquetColumn {
+		return col(name, parquetInt32, parquetNoConverted)
+	}
+	cols := []*parquetColumn{
+		col("snapshot_time", parquetInt64, parquetTimestampMillis),
+		str("namespace"),
+		str("name"),
+		str("ref_kind"),
+		str("ref_name"),
+		i32("min_replicas"),
+		i32("max_replicas"),
+		i32("current_replicas"),
+		i32("desired_replicas"),
+		str("target_metrics"),
+		str("current_metrics"),
+		str("conditions"),
+		col("demand_replicas", parquetDouble, parquetNoConverted),
+	}
+	ts := now.UnixNano() / int64(time.Millisecond)
+	for _, a := range hpa {
+		cols[0].addInt64(ts)
+		cols[1].addString(a.ObjectMeta.Namespace)
+		cols[2].addString(a.ObjectMeta.Name)
+		cols[3].addString(a.Spec.ScaleTargetRef.Kind)
+		cols[4].addString(a.Spec.ScaleTargetRef.Name)
+		cols[5].addInt32(minReplicas(a))
+		cols[6].addInt32(a.Spec.MaxReplicas)
+		cols[7].addInt32(a.Status.CurrentReplicas)
+		cols[8].addInt32(a.Status.DesiredReplicas)
+		cols[9].addString(formatMetrics(specMetrics(a)))
+		cols[10].addString(formatMetrics(statusMetrics(a)))
+		cols[11].addString(formatConditions(a.Status.Conditions))
+		cols[12].addDouble(demandReplicas(a))
+	}
+	writeParquet(buf, cols, len(hpa))
+}
+
+// objectURL returns the URL to PUT the object to and the region and
+// credentials to sign the request with. GCS is used through its S3
+// compatible XML API with HMAC keys.
+func objectURL(bucketURL, key string) (string, string, *credentials.Credentials, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return "", "", nil, err
+	}
+	key = strings.Trim(u.Path, "/") + "/" + key
+	key = strings.TrimPrefix(key, "/")
+	if u.Scheme == "gs" {
+		creds := credentials.NewStaticCredentials(snapshotGCSAccessKey.Get(), snapshotGCSSecret.Get(), "")
+		return gcsEndpoint + "/" + u.Host + "/" + key, "auto", creds, nil
+	}
+	sess, err := newAWSSession()
+	if err != nil {
+		return "", "", nil, err
+	}
+	region := aws.StringValue(sess.Config.Region)
+	if *awsEndpointURL != "" {
+		return strings.TrimSuffix(*awsEndpointURL, "/") + "/" + u.Host + "/" + key, region, sess.Config.Credentials, nil
+	}
+	e, err := endpoints.DefaultResolver().EndpointFor("s3", region)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return strings.Replace(e.URL, "://", "://"+u.Host+".", 1) + "/" + key, region, sess.Config.Credentials, nil
+}
+
+var uploadClient = &http.Client{Timeout: 30 * time.Second}
+
+func putObject(bucketURL, key, contentType string, body []byte) error {
+	target, region, creds, err := objectURL(bucketURL, key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	signer := v4.NewSigner(creds, func(s *v4.Signer) { s.DisableURIPathEscaping = true })
+	if _, err := signer.Sign(req, bytes.NewReader(body), "s3", region, time.Now()); err != nil {
+		return err
+	}
+	res, err := uploadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("put %s: %s: %s", target, res.Status, b)
+	}
+	return nil
+}
+
+func uploadSnapshot(now time.Time) error {
+	buf := &bytes.Buffer{}
+	writeSnapshotParquet(buf, cachedHpaList(), now)
+	key := now.UTC().Format("2006/01/02/hpas-20060102T150405Z.parquet")
+	return putObject(*snapshotUploadURL, key, "application/vnd.apache.parquet", buf.Bytes())
+}
+
+func uploadSnapshots() {
+	for {
+		time.Sleep(*snapshotUploadInterval)
+		if err := uploadSnapshot(time.Now()); err != nil {
+			log.Errorln("upload snapshot:", err)
+		}
+	}
+}
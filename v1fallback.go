@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+
+	as_v1 "k8s.io/api/autoscaling/v1"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// autoscaling/v1 keeps the metrics other than CPU utilization and the
+// conditions in these annotations, in the form of autoscaling/v2beta1.
+const (
+	v1MetricsAnnotation        = "autoscaling.alpha.kubernetes.io/metrics"
+	v1CurrentMetricsAnnotation = "autoscaling.alpha.kubernetes.io/current-metrics"
+	v1ConditionsAnnotation     = "autoscaling.alpha.kubernetes.io/conditions"
+)
+
+// hpaServedVersion returns the API version the HPA was read with,
+// autoscaling/v1 on clusters that do not serve autoscaling/v2beta1.
+func hpaServedVersion(a as_v2.HorizontalPodAutoscaler) string {
+	if a.TypeMeta.APIVersion == as_v1.SchemeGroupVersion.String() {
+		return a.TypeMeta.APIVersion
+	}
+	return as_v2.SchemeGroupVersion.String()
+}
+
+// hpaReadVersion returns the version of hpaServedVersion, the value of label
+// api_version.
+func hpaReadVersion(a as_v2.HorizontalPodAutoscaler) string {
+	if hpaServedVersion(a) == as_v1.SchemeGroupVersion.String() {
+		return as_v1.SchemeGroupVersion.Version
+	}
+	return as_v2.SchemeGroupVersion.Version
+}
+
+// listHpasWithFallback lists the HPAs of the namespace with autoscaling/v2beta1,
+// or with autoscaling/v1 when the cluster does not serve it.
+func listHpasWithFallback(ns string) ([]as_v2.HorizontalPodAutoscaler, error) {
+	hpa, _, _, err := listHpasWithFallbackRV(ns)
+	return hpa, err
+}
+
+// listHpasWithFallbackRV is listHpasWithFallback that also returns the
+// resourceVersion of the list and whether autoscaling/v1 was used.
+func listHpasWithFallbackRV(ns string) ([]as_v2.HorizontalPodAutoscaler, string, bool, error) {
+	return listHpasWithFallbackAs(kubeClient, ns)
+}
+
+// listHpasWithFallbackAs is listHpasWithFallbackRV with the client c.
+func listHpasWithFallbackAs(c kubernetes.Interface, ns string) ([]as_v2.HorizontalPodAutoscaler, string, bool, error) {
+	l2, err := c.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).List(meta_v1.ListOptions{})
+	if !errors.IsNotFound(err) {
+		if err != nil {
+			return nil, "", false, err
+		}
+		return l2.Items, l2.ListMeta.ResourceVersion, false, nil
+	}
+	l1, err := c.AutoscalingV1().HorizontalPodAutoscalers(ns).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, "", false, err
+	}
+	ret := make([]as_v2.HorizontalPodAutoscaler, 0, len(l1.Items))
+	for _, a := range l1.Items {
+		ret = append(ret, convertV1Hpa(a))
+	}
+	return ret, l1.ListMeta.ResourceVersion, true, nil
+}
+
+// convertV1Hpa converts an autoscaling/v1 HPA, keeping autoscaling/v1 as its
+// apiVersion for hpaServedVersion.
+func convertV1Hpa(a as_v1.HorizontalPodAutoscaler) as_v2.HorizontalPodAutoscaler {
+	ret := as_v2.HorizontalPodAutoscaler{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "HorizontalPodAutoscaler",
+			APIVersion: as_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: a.ObjectMeta,
+		Spec: as_v2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: as_v2.CrossVersionObjectReference{
+				Kind:       a.Spec.ScaleTargetRef.Kind,
+				Name:       a.Spec.ScaleTargetRef.Name,
+				APIVersion: a.Spec.ScaleTargetRef.APIVersion,
+			},
+			MinReplicas: a.Spec.MinReplicas,
+			MaxReplicas: a.Spec.MaxReplicas,
+		},
+		Status: as_v2.HorizontalPodAutoscalerStatus{
+			ObservedGeneration: a.Status.ObservedGeneration,
+			LastScaleTime:      a.Status.LastScaleTime,
+			CurrentReplicas:    a.Status.CurrentReplicas,
+			DesiredReplicas:    a.Status.DesiredReplicas,
+		},
+	}
+	if a.Spec.TargetCPUUtilizationPercentage != nil {
+		ret.Spec.Metrics = append(ret.Spec.Metrics, as_v2.MetricSpec{
+			Type: as_v2.ResourceMetricSourceType,
+			Resource: &as_v2.ResourceMetricSource{
+				Name:                     core_v1.ResourceCPU,
+				TargetAverageUtilization: a.Spec.TargetCPUUtilizationPercentage,
+			},
+		})
+	}
+	if a.Status.CurrentCPUUtilizationPercentage != nil {
+		ret.Status.CurrentMetrics = append(ret.Status.CurrentMetrics, as_v2.MetricStatus{
+			Type: as_v2.ResourceMetricSourceType,
+			Resource: &as_v2.ResourceMetricStatus{
+				Name:                      core_v1.ResourceCPU,
+				CurrentAverageUtilization: a.Status.CurrentCPUUtilizationPercentage,
+			},
+		})
+	}
+	// the annotations are best effort, a malformed one is left out
+	metrics := []as_v2.MetricSpec{}
+	if json.Unmarshal([]byte(a.ObjectMeta.Annotations[v1MetricsAnnotation]), &metrics) == nil {
+		ret.Spec.Metrics = append(ret.Spec.Metrics, metrics...)
+	}
+	current := []as_v2.MetricStatus{}
+	if json.Unmarshal([]byte(a.ObjectMeta.Annotations[v1CurrentMetricsAnnotation]), &current) == nil {
+		ret.Status.CurrentMetrics = append(ret.Status.CurrentMetrics, current...)
+	}
+	conditions := []as_v2.HorizontalPodAutoscalerCondition{}
+	if json.Unmarshal([]byte(a.ObjectMeta.Annotations[v1ConditionsAnnotation]), &conditions) == nil {
+		ret.Status.Conditions = conditions
+	}
+	ret.ObjectMeta.Annotations = map[string]string{}
+	for k, v := range a.ObjectMeta.Annotations {
+		if k != v1MetricsAnnotation && k != v1CurrentMetricsAnnotation && k != v1ConditionsAnnotation {
+			ret.ObjectMeta.Annotations[k] = v
+		}
+	}
+	return ret
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	as_v1 "k8s.io/api/autoscaling/v1"
+	as_v2 "k8s.io/api/autoscaling/v2beta1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestWatchedHpaVersion(t *testing.T) {
+	cpu := int32(80)
+	v1 := &as_v1.HorizontalPodAutoscaler{}
+	v1.ObjectMeta.Name = "web"
+	v1.ObjectMeta.Annotations = map[string]string{v1ConditionsAnnotation: `[{"type":"AbleToScale","status":"True"}]`}
+	v1.Spec.TargetCPUUtilizationPercentage = &cpu
+	v2 := &as_v2.HorizontalPodAutoscaler{}
+	v2.ObjectMeta.Name = "api"
+
+	for _, c := range []struct {
+		obj     watch.Event
+		ok      bool
+		served  string
+		version string
+	}{
+		{watch.Event{Type: watch.Added, Object: v1}, true, "autoscaling/v1", "v1"},
+		{watch.Event{Type: watch.Modified, Object: v2}, true, "autoscaling/v2beta1", "v2beta1"},
+		{watch.Event{Type: watch.Error}, false, "", ""},
+	} {
+		a, ok := watchedHpa(c.obj)
+		if ok != c.ok {
+			t.Errorf("%v: ok is %v, want %v", c.obj.Type, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got := hpaServedVersion(a); got != c.served {
+			t.Errorf("%s: served version %s, want %s", a.ObjectMeta.Name, got, c.served)
+		}
+		if got := hpaBaseLabels(a)["api_version"]; got != c.version {
+			t.Errorf("%s: api_version %s, want %s", a.ObjectMeta.Name, got, c.version)
+		}
+	}
+
+	a, _ := watchedHpa(watch.Event{Type: watch.Added, Object: v1})
+	if len(a.Spec.Metrics) != 1 || *a.Spec.Metrics[0].Resource.TargetAverageUtilization != cpu {
+		t.Errorf("CPU target not converted: %v", a.Spec.Metrics)
+	}
+	if len(a.Status.Conditions) != 1 || len(a.ObjectMeta.Annotations) != 0 {
+		t.Errorf("conditions annotation not converted: %v %v", a.Status.Conditions, a.ObjectMeta.Annotations)
+	}
+}
+
+func TestBaseLabelsCopied(t *testing.T) {
+	a := append(baseLabels, "a")
+	b := append(baseLabels, "b")
+	if a[len(a)-1] != "a" || b[len(b)-1] != "b" {
+		t.Errorf("appending to baseLabels shares memory: %v %v", a, b)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// webhookConfig sends findings as HTTP requests with templated URL, headers
+// and body, e.g. to Jira automation or an internal chatops API.
+type webhookConfig struct {
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	// HeaderFiles are headers read from files, e.g. tokens in a mounted
+	// Secret. The files are re-read when they change.
+	HeaderFiles map[string]string `yaml:"headerFiles"`
+	Body        string            `yaml:"body"`
+	// Batch sends all findings of an evaluation in one request instead of
+	// one request per finding.
+	Batch   bool          `yaml:"batch"`
+	Timeout time.Duration `yaml:"timeout"`
+	TLS     tlsFiles      `yaml:"tls"`
+}
+
+// tlsFiles is the TLS configuration of a receiver in the notify config.
+type tlsFiles struct {
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+func (t tlsFiles) HTTPClient(name string, timeout time.Duration) (*http.Client, error) {
+	c := &clientTLS{
+		prefix:             name + ".tls.",
+		caFile:             &t.CAFile,
+		certFile:           &t.CertFile,
+		keyFile:            &t.KeyFile,
+		insecureSkipVerify: &t.InsecureSkipVerify,
+	}
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return c.HTTPClient(timeout)
+}
+
+const defaultWebhookBody = `{{ json .Findings }}`
+
+type webhookReceiver struct {
+	config  *webhookConfig
+	client  *http.Client
+	url     *template.Template
+	body    *template.Template
+	headers map[string]*template.Template
+	secrets map[string]*secret
+}
+
+func newWebhookReceiver(c *webhookConfig) (*webhookReceiver, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("webhook has no url")
+	}
+	if c.Method == "" {
+		c.Method = http.MethodPost
+	}
+	if c.Body == "" {
+		c.Body = defaultWebhookBody
+	}
+	r := &webhookReceiver{config: c, headers: map[string]*template.Template{}, secrets: map[string]*secret{}}
+	var err error
+	if r.url, err = parseNotifyTemplate("url", c.URL); err != nil {
+		return nil, err
+	}
+	if r.body, err = parseNotifyTemplate("body", c.Body); err != nil {
+		return nil, err
+	}
+	for k, v := range c.Headers {
+		if r.headers[k], err = parseNotifyTemplate("header "+k, v); err != nil {
+			return nil, err
+		}
+	}
+	for k, path := range c.HeaderFiles {
+		value, file := "", path
+		r.secrets[k] = &secret{name: "header " + k, value: &value, file: &file}
+	}
+	if r.client, err = c.TLS.HTTPClient("webhook", c.Timeout); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *webhookReceiver) Notify(fs []finding) error {
+	if r.config.Batch {
+		return r.send(newNotifyData(fs))
+	}
+	for _, f := range fs {
+		if err := r.send(newNotifyData([]finding{f})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *webhookReceiver) send(data notifyData) error {
+	url, err := executeTemplate(r.url, data)
+	if err != nil {
+		return err
+	}
+	body, err := executeTemplate(r.body, data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(r.config.Method, strings.TrimSpace(url), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, t := range r.headers {
+		v, err := executeTemplate(t, data)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(k, v)
+	}
+	for k, s := range r.secrets {
+		req.Header.Set(k, s.Get())
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s %s: %s: %s", r.config.Method, req.URL.Host, res.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}